@@ -0,0 +1,237 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"net"
+	"strings"
+	"time"
+)
+
+//=============================================================================
+
+type ApiKey struct {
+	Id         string
+	Hash       string
+	Owner      string
+	Roles      []role.Role
+	Expiry     time.Time
+	AllowedIps []*net.IPNet
+}
+
+//=============================================================================
+
+type ApiKeyStore interface {
+	Lookup(hash string) (*ApiKey, error)
+}
+
+//=============================================================================
+
+type ApiKeyAuthenticator struct {
+	store ApiKeyStore
+}
+
+//=============================================================================
+
+type memoryApiKeyStore struct {
+	keys map[string]*ApiKey
+}
+
+//=============================================================================
+//===
+//=== Public functions
+//===
+//=============================================================================
+
+func NewApiKeyAuthenticator(store ApiKeyStore) *ApiKeyAuthenticator {
+	return &ApiKeyAuthenticator{
+		store: store,
+	}
+}
+
+//=============================================================================
+
+func (ak *ApiKeyAuthenticator) Authenticate(key string, clientIp string) (*UserSession, error) {
+	apiKey, err := ak.store.Lookup(HashApiKey(key))
+	if err != nil {
+		return nil, err
+	}
+
+	if apiKey == nil {
+		return nil, errors.New("unknown API key")
+	}
+
+	if !apiKey.Expiry.IsZero() && !time.Now().Before(apiKey.Expiry) {
+		return nil, errors.New("API key is expired: "+ apiKey.Id)
+	}
+
+	if !apiKey.isIpAllowed(clientIp) {
+		return nil, errors.New("API key '"+ apiKey.Id +"' cannot be used from: "+ clientIp)
+	}
+
+	userRoles := map[role.Role]any{}
+	for _, r := range apiKey.Roles {
+		userRoles[r] = nil
+	}
+
+	return &UserSession{
+		SessionID : apiKey.Id,
+		Username  : apiKey.Owner,
+		OnBehalfOf: apiKey.Owner,
+		Expiry    : apiKey.Expiry,
+		Roles     : userRoles,
+		AuthMethod: AuthMethodApiKey,
+	}, nil
+}
+
+//=============================================================================
+
+func NewMemoryApiKeyStore(keys []*ApiKey) ApiKeyStore {
+	store := &memoryApiKeyStore{
+		keys: map[string]*ApiKey{},
+	}
+
+	for _, k := range keys {
+		store.keys[strings.ToLower(k.Hash)] = k
+	}
+
+	return store
+}
+
+//=============================================================================
+
+func NewConfigApiKeyStore(cfg []core.ApiKey) (ApiKeyStore, error) {
+	var keys []*ApiKey
+
+	for _, ck := range cfg {
+		k, err := convertApiKey(&ck)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	return NewMemoryApiKeyStore(keys), nil
+}
+
+//=============================================================================
+
+func (s *memoryApiKeyStore) Lookup(hash string) (*ApiKey, error) {
+	return s.keys[hash], nil
+}
+
+//=============================================================================
+
+func HashApiKey(key string) string {
+	return hashToken(key)
+}
+
+//=============================================================================
+
+func GenerateApiKey() (key string, hash string, err error) {
+	data := make([]byte, 32)
+	if _, err = rand.Read(data); err != nil {
+		return "", "", err
+	}
+
+	key = "bf_"+ base64.RawURLEncoding.EncodeToString(data)
+	return key, HashApiKey(key), nil
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (k *ApiKey) isIpAllowed(clientIp string) bool {
+	if len(k.AllowedIps) == 0 {
+		return true
+	}
+
+	ip := net.ParseIP(clientIp)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range k.AllowedIps {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//=============================================================================
+
+func convertApiKey(ck *core.ApiKey) (*ApiKey, error) {
+	if ck.Hash == "" || ck.Owner == "" {
+		return nil, errors.New("API key must have a hash and an owner: "+ ck.Id)
+	}
+
+	k := &ApiKey{
+		Id   : ck.Id,
+		Hash : ck.Hash,
+		Owner: ck.Owner,
+	}
+
+	for _, r := range ck.Roles {
+		k.Roles = append(k.Roles, role.Role(r))
+	}
+
+	if ck.Expiry != "" {
+		expiry, err := time.Parse(time.RFC3339, ck.Expiry)
+		if err != nil {
+			return nil, errors.New("invalid expiry for API key '"+ ck.Id +"': "+ err.Error())
+		}
+		k.Expiry = expiry
+	}
+
+	for _, ip := range ck.AllowedIps {
+		if !strings.Contains(ip, "/") {
+			if strings.Contains(ip, ":") {
+				ip += "/128"
+			} else {
+				ip += "/32"
+			}
+		}
+
+		_, n, err := net.ParseCIDR(ip)
+		if err != nil {
+			return nil, errors.New("invalid IP restriction for API key '"+ ck.Id +"': "+ err.Error())
+		}
+		k.AllowedIps = append(k.AllowedIps, n)
+	}
+
+	return k, nil
+}
+
+//=============================================================================
@@ -0,0 +1,116 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/bit-fever/core/req"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+func TestApiKeyAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	validKey,   validHash,   _ := GenerateApiKey()
+	expiredKey, expiredHash, _ := GenerateApiKey()
+	remoteKey,  remoteHash,  _ := GenerateApiKey()
+
+	store, err := NewConfigApiKeyStore([]core.ApiKey{
+		{ Id: "k1", Hash: validHash,   Owner: "scheduler", Roles: []string{ "user" }},
+		{ Id: "k2", Hash: expiredHash, Owner: "scheduler", Roles: []string{ "user" }, Expiry: time.Now().Add(-time.Hour).Format(time.RFC3339) },
+		{ Id: "k3", Hash: remoteHash,  Owner: "scheduler", Roles: []string{ "user" }, AllowedIps: []string{ "10.1.0.0/16" }},
+	})
+	if err != nil {
+		t.Fatalf("NewConfigApiKeyStore failed: %v", err)
+	}
+
+	key := newTestKey(t)
+	oc  := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	oc.SetApiKeyAuthentication(NewApiKeyAuthenticator(store), true)
+
+	var session *UserSession
+	handler := func(c *Context) {
+		session = c.Session
+		_ = c.ReturnObject("ok")
+	}
+
+	router := gin.New()
+	router.GET("/api",         oc.Secure(handler, roles.User))
+	router.GET("/interactive", oc.Secure(handler, roles.User, WithApiKey(false)))
+
+	cases := []struct {
+		path   string
+		key    string
+		status int
+	}{
+		{ "/api",         validKey,    http.StatusOK           },
+		{ "/api",         expiredKey,  http.StatusUnauthorized },
+		{ "/api",         remoteKey,   http.StatusUnauthorized },
+		{ "/api",         "bf_random", http.StatusUnauthorized },
+		{ "/interactive", validKey,    http.StatusUnauthorized },
+	}
+
+	for i, tc := range cases {
+		rr := serve(router, "GET", tc.path, "", map[string]string{ req.ApiKey: tc.key })
+		if rr.Code != tc.status {
+			t.Errorf("Case %d: expected status %d but got %d", i, tc.status, rr.Code)
+		}
+	}
+
+	serve(router, "GET", "/api", "", map[string]string{ req.ApiKey: validKey })
+	if session.Username != "scheduler" || session.AuthMethod != AuthMethodApiKey {
+		t.Errorf("Unexpected session: %+v", session)
+	}
+
+	//--- The allow-list checks the peer address, not X-Forwarded-For
+
+	if rr := serve(router, "GET", "/api", "", map[string]string{ req.ApiKey: remoteKey, "X-Forwarded-For": "10.1.2.3" }); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d but got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+//=============================================================================
+
+func TestConfigApiKeyStoreErrors(t *testing.T) {
+	configs := [][]core.ApiKey{
+		{{ Id: "no-hash", Owner: "x" }},
+		{{ Id: "bad-expiry", Hash: "abc", Owner: "x", Expiry: "tomorrow" }},
+		{{ Id: "bad-ip",     Hash: "abc", Owner: "x", AllowedIps: []string{ "10.0.0.300" }}},
+	}
+
+	for _, cfg := range configs {
+		if _, err := NewConfigApiKeyStore(cfg); err == nil {
+			t.Errorf("Expected an error for key '%v'", cfg[0].Id)
+		}
+	}
+}
+
+//=============================================================================
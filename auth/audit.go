@@ -0,0 +1,292 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"encoding/json"
+	"github.com/bit-fever/core/msg"
+	"github.com/bit-fever/core/req"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//=============================================================================
+
+const auditQueueSize = 1024
+
+//=============================================================================
+
+type AuditRecord struct {
+	Timestamp  time.Time       `json:"timestamp"`
+	Username   string          `json:"username"`
+	OnBehalfOf string          `json:"onBehalfOf"`
+	AuthMethod string          `json:"authMethod"`
+	Client     string          `json:"client"`
+	Method     string          `json:"method"`
+	Route      string          `json:"route"`
+	Path       string          `json:"path"`
+	EntityId   uint            `json:"entityId,omitempty"`
+	Status     int             `json:"status"`
+	LatencyMs  int64           `json:"latencyMs"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+}
+
+//=============================================================================
+
+type AuditSink interface {
+	Write(r *AuditRecord) error
+}
+
+//=============================================================================
+
+type AuditRecorder struct {
+	sync.RWMutex
+	sinks   []AuditSink
+	records chan *AuditRecord
+	done    chan struct{}
+	closed  bool
+	dropped atomic.Uint64
+}
+
+//=============================================================================
+//===
+//=== Recorder
+//===
+//=============================================================================
+
+func NewAuditRecorder(sinks ...AuditSink) *AuditRecorder {
+	ar := &AuditRecorder{
+		sinks  : sinks,
+		records: make(chan *AuditRecord, auditQueueSize),
+		done   : make(chan struct{}),
+	}
+
+	go ar.run()
+
+	return ar
+}
+
+//=============================================================================
+//--- Never blocks the request: when the queue is full (slow sinks) or the
+//--- recorder is closed, the record is dropped and counted
+
+func (ar *AuditRecorder) Record(r *AuditRecord) {
+	ar.RLock()
+	defer ar.RUnlock()
+
+	if !ar.closed {
+		select {
+		case ar.records <- r:
+			return
+		default:
+		}
+	}
+
+	if dropped := ar.dropped.Add(1); dropped == 1 || dropped % auditQueueSize == 0 {
+		slog.Warn("Audit records dropped", "dropped", dropped, "closed", ar.closed)
+	}
+}
+
+//=============================================================================
+
+func (ar *AuditRecorder) Dropped() uint64 {
+	return ar.dropped.Load()
+}
+
+//=============================================================================
+
+func (ar *AuditRecorder) Close() {
+	ar.Lock()
+	if ar.closed {
+		ar.Unlock()
+		return
+	}
+	ar.closed = true
+	close(ar.records)
+	ar.Unlock()
+
+	<-ar.done
+}
+
+//=============================================================================
+//===
+//=== Sinks
+//===
+//=============================================================================
+
+type fileAuditSink struct {
+	sync.Mutex
+	file *os.File
+}
+
+//=============================================================================
+
+func NewFileAuditSink(path string) (AuditSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileAuditSink{ file: f }, nil
+}
+
+//=============================================================================
+
+func (s *fileAuditSink) Write(r *AuditRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
+
+//=============================================================================
+
+func (s *fileAuditSink) Close() error {
+	return s.file.Close()
+}
+
+//=============================================================================
+
+type messageAuditSink struct {
+}
+
+//=============================================================================
+
+func NewMessageAuditSink() AuditSink {
+	return &messageAuditSink{}
+}
+
+//=============================================================================
+
+func (s *messageAuditSink) Write(r *AuditRecord) error {
+	return msg.SendMessage(msg.ExEvent, msg.SourceAudit, msg.TypeCreate, r)
+}
+
+//=============================================================================
+
+type logAuditSink struct {
+	logger *slog.Logger
+}
+
+//=============================================================================
+
+func NewLogAuditSink(logger *slog.Logger) AuditSink {
+	return &logAuditSink{ logger: logger }
+}
+
+//=============================================================================
+
+func (s *logAuditSink) Write(r *AuditRecord) error {
+	s.logger.Info("Audit",
+		slog.String("username",   r.Username),
+		slog.String("onBehalfOf", r.OnBehalfOf),
+		slog.String("method",     r.Method),
+		slog.String("route",      r.Route),
+		slog.Any   ("entityId",   r.EntityId),
+		slog.Int   ("status",     r.Status),
+		slog.Int64 ("latencyMs",  r.LatencyMs))
+	return nil
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (ar *AuditRecorder) run() {
+	defer close(ar.done)
+
+	for r := range ar.records {
+		for _, s := range ar.sinks {
+			if err := s.Write(r); err != nil {
+				slog.Error("Cannot write audit record", "route", r.Route, "username", r.Username, "error", err.Error())
+			}
+		}
+	}
+
+	for _, s := range ar.sinks {
+		if c, ok := s.(io.Closer); ok {
+			_ = c.Close()
+		}
+	}
+}
+
+//=============================================================================
+
+func newAuditRecord(c *gin.Context, us *UserSession) *AuditRecord {
+	r := &AuditRecord{
+		Timestamp : time.Now(),
+		Username  : us.Username,
+		OnBehalfOf: us.OnBehalfOf,
+		AuthMethod: us.AuthMethod,
+		Client    : c.ClientIP(),
+		Method    : c.Request.Method,
+		Route     : c.FullPath(),
+		Path      : c.Request.URL.Path,
+	}
+
+	if c.Param("id") != "" {
+		if id, err := req.GetIdFromUrl(c); err == nil {
+			r.EntityId = id
+		}
+	}
+
+	return r
+}
+
+//=============================================================================
+
+func (r *AuditRecord) complete(c *gin.Context, us *UserSession) {
+	r.OnBehalfOf = us.OnBehalfOf
+	r.Status     = c.Writer.Status()
+	r.LatencyMs  = time.Since(r.Timestamp).Milliseconds()
+}
+
+//=============================================================================
+
+func snapshot(entity any) json.RawMessage {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		slog.Error("Cannot marshal audit snapshot", "error", err.Error())
+		return nil
+	}
+
+	return data
+}
+
+//=============================================================================
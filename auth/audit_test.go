@@ -0,0 +1,239 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/bit-fever/core/req"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+type memoryAuditSink struct {
+	sync.Mutex
+	records []*AuditRecord
+}
+
+//=============================================================================
+
+func (s *memoryAuditSink) Write(r *AuditRecord) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.records = append(s.records, r)
+	return nil
+}
+
+//=============================================================================
+
+type order struct {
+	Id     uint   `json:"id"`
+	Status string `json:"status"`
+}
+
+//=============================================================================
+
+func TestSecureAudit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key  := newTestKey(t)
+	oc   := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	sink := &memoryAuditSink{}
+	ar   := NewAuditRecorder(sink)
+	oc.SetAuditRecorder(ar, false)
+
+	router := gin.New()
+	router.PUT("/orders/:id", oc.Secure(func(c *Context) {
+		o := &order{ Id: 42, Status: "open" }
+		c.AuditBefore(o)
+		o.Status = "filled"
+		c.AuditAfter(o)
+		_ = c.ReturnObject(o)
+	}, roles.Admin_User_Service, WithAudit(true)))
+
+	router.GET("/orders", oc.Secure(func(c *Context) {
+		_ = c.ReturnObject("ok")
+	}, roles.Admin_User_Service))
+
+	service := mintToken(t, key, userClaims("inventory", role.Service))
+	admin   := mintToken(t, key, userClaims("bob",       role.Admin))
+
+	serve(router, "PUT", "/orders/42", service, map[string]string{ req.OnBehalfOf: "alice" })
+	serve(router, "PUT", "/orders/x",  admin,   nil)
+	serve(router, "GET", "/orders",    service, nil)
+	serve(router, "PUT", "/orders/42", "",      nil)
+	ar.Close()
+
+	if len(sink.records) != 2 {
+		t.Fatalf("Expected 2 audit records but got %d", len(sink.records))
+	}
+
+	r := sink.records[0]
+	if r.Username != "inventory" || r.OnBehalfOf != "alice" || r.Method != "PUT" || r.Route != "/orders/:id" || r.EntityId != 42 || r.Status != http.StatusOK {
+		t.Errorf("Unexpected audit record: %+v", r)
+	}
+
+	var before, after order
+	if err := json.Unmarshal(r.Before, &before); err != nil || before.Status != "open" {
+		t.Errorf("Unexpected 'before' snapshot: %s", r.Before)
+	}
+	if err := json.Unmarshal(r.After, &after); err != nil || after.Status != "filled" {
+		t.Errorf("Unexpected 'after' snapshot: %s", r.After)
+	}
+
+	r = sink.records[1]
+	if r.Username != "bob" || r.EntityId != 0 || r.Status != http.StatusOK {
+		t.Errorf("Unexpected audit record: %+v", r)
+	}
+}
+
+//=============================================================================
+
+func TestSecureAuditDenied(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key  := newTestKey(t)
+	oc   := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	sink := &memoryAuditSink{}
+	ar   := NewAuditRecorder(sink)
+	oc.SetAuditRecorder(ar, true)
+
+	router := gin.New()
+	router.DELETE("/orders/:id", oc.Secure(func(c *Context) {
+		c.Gin.Status(http.StatusNoContent)
+	}, roles.Admin))
+
+	serve(router, "DELETE", "/orders/7", mintToken(t, key, userClaims("mallory", role.User)), nil)
+	ar.Close()
+
+	if len(sink.records) != 1 {
+		t.Fatalf("Expected 1 audit record but got %d", len(sink.records))
+	}
+
+	if r := sink.records[0]; r.Username != "mallory" || r.EntityId != 7 || r.Status != http.StatusForbidden {
+		t.Errorf("Unexpected audit record: %+v", r)
+	}
+}
+
+//=============================================================================
+
+func TestFileAuditSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := NewFileAuditSink(path)
+	if err != nil {
+		t.Fatalf("NewFileAuditSink failed: %v", err)
+	}
+
+	ar := NewAuditRecorder(sink)
+	ar.Record(&AuditRecord{ Username: "alice", Route: "/orders", Status: http.StatusOK })
+	ar.Record(&AuditRecord{ Username: "bob",   Route: "/orders", Status: http.StatusForbidden })
+	ar.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Cannot open the audit file: %v", err)
+	}
+	defer f.Close()
+
+	var users []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r AuditRecord
+		if err = json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("Invalid audit line: %v", err)
+		}
+		users = append(users, r.Username)
+	}
+
+	if len(users) != 2 || users[0] != "alice" || users[1] != "bob" {
+		t.Errorf("Unexpected audit lines: %v", users)
+	}
+}
+
+//=============================================================================
+
+type blockingAuditSink struct {
+	release chan struct{}
+}
+
+//=============================================================================
+
+func (s *blockingAuditSink) Write(r *AuditRecord) error {
+	<-s.release
+	return nil
+}
+
+//=============================================================================
+
+func TestAuditRecorderNeverBlocks(t *testing.T) {
+	sink := &blockingAuditSink{ release: make(chan struct{}) }
+	ar   := NewAuditRecorder(sink)
+
+	//--- The worker holds one record, the queue the others
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < auditQueueSize + 10; i++ {
+			ar.Record(&AuditRecord{})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Record blocked on a full queue")
+	}
+
+	if dropped := ar.Dropped(); dropped == 0 {
+		t.Errorf("Expected dropped records")
+	}
+
+	close(sink.release)
+	ar.Close()
+	ar.Close()
+
+	//--- Records after Close are dropped, not sent on the closed channel
+
+	before := ar.Dropped()
+	ar.Record(&AuditRecord{})
+
+	if ar.Dropped() != before + 1 {
+		t.Errorf("Expected the record to be dropped after Close")
+	}
+}
+
+//=============================================================================
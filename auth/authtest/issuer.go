@@ -0,0 +1,253 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package authtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/bit-fever/core/auth"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/req"
+	"github.com/go-jose/go-jose/v4"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+const (
+	DefaultExpiry = time.Hour
+	keyId         = "authtest"
+)
+
+//=============================================================================
+
+type Issuer struct {
+	t      testing.TB
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	signer jose.Signer
+}
+
+//=============================================================================
+
+type User struct {
+	Username  string
+	Roles     []role.Role
+	SessionID string
+	ExpiresIn time.Duration
+	Claims    map[string]any
+}
+
+//=============================================================================
+//===
+//=== Public functions
+//===
+//=============================================================================
+
+func NewIssuer(t testing.TB) *Issuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Cannot generate the issuer key: %v", err)
+	}
+
+	opts   := (&jose.SignerOptions{}).WithType("JWT").WithHeader(jose.HeaderKey("kid"), keyId)
+	signer, err := jose.NewSigner(jose.SigningKey{ Algorithm: jose.RS256, Key: key }, opts)
+	if err != nil {
+		t.Fatalf("Cannot create the token signer: %v", err)
+	}
+
+	i := &Issuer{
+		t     : t,
+		key   : key,
+		signer: signer,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", i.discovery)
+	mux.HandleFunc("GET /protocol/openid-connect/certs",    i.jwks)
+
+	i.server = httptest.NewServer(mux)
+	t.Cleanup(i.server.Close)
+
+	return i
+}
+
+//=============================================================================
+
+func (i *Issuer) URL() string {
+	return i.server.URL
+}
+
+//=============================================================================
+
+func (i *Issuer) Client() *http.Client {
+	return i.server.Client()
+}
+
+//=============================================================================
+
+func (i *Issuer) Controller(config any) (*auth.OidcController, error) {
+	verifier, err := auth.NewDiscoveryVerifier(i.URL(), i.Client())
+	if err != nil {
+		return nil, err
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return auth.NewOidcControllerWithVerifier(verifier, logger, config), nil
+}
+
+//=============================================================================
+
+func (i *Issuer) Token(u User) string {
+	i.t.Helper()
+
+	now := time.Now()
+
+	expiresIn := u.ExpiresIn
+	if expiresIn == 0 {
+		expiresIn = DefaultExpiry
+	}
+
+	sessionId := u.SessionID
+	if sessionId == "" {
+		sessionId = randomId()
+	}
+
+	claims := map[string]any{
+		"sub"               : "sub-"+ u.Username,
+		"jti"               : randomId(),
+		"sid"               : sessionId,
+		"azp"               : "authtest",
+		"preferred_username": u.Username,
+		"realm_access"      : map[string]any{ "roles": u.Roles },
+		"iat"               : now.Unix(),
+		"exp"               : now.Add(expiresIn).Unix(),
+	}
+
+	for k, v := range u.Claims {
+		claims[k] = v
+	}
+
+	return i.Mint(claims)
+}
+
+//=============================================================================
+//--- Signs any set of claims. 'iss' is set to the issuer unless given
+
+func (i *Issuer) Mint(claims map[string]any) string {
+	i.t.Helper()
+
+	all := map[string]any{ "iss": i.URL() }
+	for k, v := range claims {
+		all[k] = v
+	}
+
+	payload, err := json.Marshal(all)
+	if err != nil {
+		i.t.Fatalf("Cannot marshal the token claims: %v", err)
+	}
+
+	jws, err := i.signer.Sign(payload)
+	if err != nil {
+		i.t.Fatalf("Cannot sign the token: %v", err)
+	}
+
+	token, err := jws.CompactSerialize()
+	if err != nil {
+		i.t.Fatalf("Cannot serialize the token: %v", err)
+	}
+
+	return token
+}
+
+//=============================================================================
+
+func Serve(h http.Handler, method string, path string, token string, onBehalfOf string) *httptest.ResponseRecorder {
+	rq := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		rq.Header.Set("Authorization", "Bearer "+ token)
+	}
+
+	if onBehalfOf != "" {
+		rq.Header.Set(req.OnBehalfOf, onBehalfOf)
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, rq)
+
+	return rr
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, map[string]any{
+		"issuer"                               : i.URL(),
+		"authorization_endpoint"               : i.URL() +"/protocol/openid-connect/auth",
+		"token_endpoint"                       : i.URL() +"/protocol/openid-connect/token",
+		"jwks_uri"                             : i.URL() +"/protocol/openid-connect/certs",
+		"id_token_signing_alg_values_supported": []string{ "RS256" },
+	})
+}
+
+//=============================================================================
+
+func (i *Issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, &jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{
+			{ Key: &i.key.PublicKey, KeyID: keyId, Algorithm: string(jose.RS256), Use: "sig" },
+		},
+	})
+}
+
+//=============================================================================
+
+func randomId() string {
+	data := make([]byte, 16)
+	_, _ = rand.Read(data)
+	return hex.EncodeToString(data)
+}
+
+//=============================================================================
+
+func writeJson(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", req.ApplicationJson)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+//=============================================================================
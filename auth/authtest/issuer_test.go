@@ -0,0 +1,110 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package authtest
+
+import (
+	"github.com/bit-fever/core/auth"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+func TestIssuerEndToEnd(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	issuer  := NewIssuer(t)
+	oc, err := issuer.Controller(nil)
+	if err != nil {
+		t.Fatalf("Controller failed: %v", err)
+	}
+
+	var session *auth.UserSession
+	router := gin.New()
+	router.GET("/api", oc.Secure(func(c *auth.Context) {
+		session = c.Session
+		_ = c.ReturnObject("ok")
+	}, roles.Admin_User_Service))
+
+	cases := []struct {
+		user       User
+		onBehalfOf string
+		status     int
+	}{
+		{ User{ Username: "alice",     Roles: []role.Role{ role.User }, SessionID: "s-1" },        "",      http.StatusOK           },
+		{ User{ Username: "alice",     Roles: []role.Role{ "trader" }},                            "",      http.StatusForbidden    },
+		{ User{ Username: "alice",     Roles: []role.Role{ role.User }, ExpiresIn: -time.Minute }, "",      http.StatusUnauthorized },
+		{ User{ Username: "alice",     Roles: []role.Role{ role.User }},                           "bob",   http.StatusForbidden    },
+		{ User{ Username: "inventory", Roles: []role.Role{ role.Service }},                        "alice", http.StatusOK           },
+	}
+
+	for i, tc := range cases {
+		session = nil
+
+		rr := Serve(router, "GET", "/api", issuer.Token(tc.user), tc.onBehalfOf)
+		if rr.Code != tc.status {
+			t.Errorf("Case %d: expected status %d but got %d", i, tc.status, rr.Code)
+		}
+	}
+
+	rr := Serve(router, "GET", "/api", issuer.Token(User{ Username: "alice", Roles: []role.Role{ role.User }, SessionID: "s-9" }), "")
+	if rr.Code != http.StatusOK || session.Username != "alice" || session.SessionID != "s-9" || session.Issuer != issuer.URL() {
+		t.Errorf("Unexpected session: %+v", session)
+	}
+}
+
+//=============================================================================
+
+func TestIssuerRejectsForeignTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	issuer  := NewIssuer(t)
+	foreign := NewIssuer(t)
+	oc, err := issuer.Controller(nil)
+	if err != nil {
+		t.Fatalf("Controller failed: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/api", oc.Secure(func(c *auth.Context) {
+		_ = c.ReturnObject("ok")
+	}, roles.User))
+
+	user := User{ Username: "alice", Roles: []role.Role{ role.User }}
+
+	if rr := Serve(router, "GET", "/api", foreign.Token(user), ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d but got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	if rr := Serve(router, "GET", "/api", foreign.Mint(map[string]any{ "iss": issuer.URL() }), ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d but got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,211 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//=============================================================================
+
+const DefaultCacheSize = 10000
+
+//=============================================================================
+
+type expiringCache[T any] struct {
+	sync.Mutex
+	maxSize int
+	entries map[string]*cacheEntry[T]
+	hits    atomic.Uint64
+	misses  atomic.Uint64
+}
+
+//=============================================================================
+
+type CacheStats struct {
+	Hits    uint64
+	Misses  uint64
+	Entries int
+	MaxSize int
+}
+
+//=============================================================================
+
+type cacheEntry[T any] struct {
+	value  T
+	expiry time.Time
+}
+
+//=============================================================================
+
+func newExpiringCache[T any](maxSize int) *expiringCache[T] {
+	if maxSize <= 0 {
+		maxSize = DefaultCacheSize
+	}
+
+	return &expiringCache[T]{
+		maxSize: maxSize,
+		entries: map[string]*cacheEntry[T]{},
+	}
+}
+
+//=============================================================================
+
+func (ec *expiringCache[T]) Get(key string) (T, bool) {
+	ec.Lock()
+	defer ec.Unlock()
+
+	e, ok := ec.entries[key]
+	if !ok {
+		ec.misses.Add(1)
+		var zero T
+		return zero, false
+	}
+
+	if !time.Now().Before(e.expiry) {
+		delete(ec.entries, key)
+		ec.misses.Add(1)
+		var zero T
+		return zero, false
+	}
+
+	ec.hits.Add(1)
+	return e.value, true
+}
+
+//=============================================================================
+
+func (ec *expiringCache[T]) Put(key string, value T, expiry time.Time) {
+	if !time.Now().Before(expiry) {
+		return
+	}
+
+	ec.Lock()
+	defer ec.Unlock()
+
+	if _, found := ec.entries[key]; !found && len(ec.entries) >= ec.maxSize {
+		ec.purge()
+	}
+
+	ec.entries[key] = &cacheEntry[T]{
+		value : value,
+		expiry: expiry,
+	}
+}
+
+//=============================================================================
+
+func (ec *expiringCache[T]) Take(key string) (T, bool) {
+	ec.Lock()
+	defer ec.Unlock()
+
+	e, ok := ec.entries[key]
+	if ok {
+		delete(ec.entries, key)
+	}
+
+	if !ok || !time.Now().Before(e.expiry) {
+		var zero T
+		return zero, false
+	}
+
+	return e.value, true
+}
+
+//=============================================================================
+
+func (ec *expiringCache[T]) Remove(key string) {
+	ec.Lock()
+	defer ec.Unlock()
+
+	delete(ec.entries, key)
+}
+
+//=============================================================================
+
+func (ec *expiringCache[T]) Clear() {
+	ec.Lock()
+	defer ec.Unlock()
+
+	ec.entries = map[string]*cacheEntry[T]{}
+}
+
+//=============================================================================
+
+func (ec *expiringCache[T]) Len() int {
+	ec.Lock()
+	defer ec.Unlock()
+
+	return len(ec.entries)
+}
+
+//=============================================================================
+
+func (ec *expiringCache[T]) Stats() CacheStats {
+	return CacheStats{
+		Hits   : ec.hits.Load(),
+		Misses : ec.misses.Load(),
+		Entries: ec.Len(),
+		MaxSize: ec.maxSize,
+	}
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (ec *expiringCache[T]) purge() {
+	now := time.Now()
+
+	for k, e := range ec.entries {
+		if !now.Before(e.expiry) {
+			delete(ec.entries, k)
+		}
+	}
+
+	//--- Still full: drop random entries (map iteration order is random)
+
+	for k := range ec.entries {
+		if len(ec.entries) < ec.maxSize {
+			break
+		}
+		delete(ec.entries, k)
+	}
+}
+
+//=============================================================================
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+//=============================================================================
@@ -0,0 +1,164 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/bit-fever/core/req"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+func TestExpiringCache(t *testing.T) {
+	ec := newExpiringCache[int](3)
+
+	ec.Put("a", 1, time.Now().Add(time.Hour))
+	ec.Put("b", 2, time.Now().Add(-time.Second))
+
+	if v, ok := ec.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected a cached value for 'a'")
+	}
+
+	if _, ok := ec.Get("b"); ok {
+		t.Errorf("Expired values must not be cached")
+	}
+
+	for i := 0; i < 10; i++ {
+		ec.Put(strconv.Itoa(i), i, time.Now().Add(time.Hour))
+	}
+
+	stats := ec.Stats()
+	if stats.Entries > 3 {
+		t.Errorf("Cache exceeded its maximum size: %d", stats.Entries)
+	}
+
+	if stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+//=============================================================================
+
+func TestSecureTokenCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key := newTestKey(t)
+	oc  := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+
+	var onBehalfOf []string
+	router := gin.New()
+	router.GET("/api", oc.Secure(func(c *Context) {
+		onBehalfOf = append(onBehalfOf, c.Session.OnBehalfOf)
+
+		//--- A handler changing its session must not change the cached one
+		c.Session.Roles[role.Admin] = nil
+		c.Session.Scopes = append(c.Session.Scopes[:0], "tampered")
+
+		_ = c.ReturnObject("ok")
+	}, roles.Service))
+	router.GET("/admin", oc.Secure(func(c *Context) {
+		_ = c.ReturnObject("ok")
+	}, roles.Admin))
+
+	claims := userClaims("inventory", role.Service)
+	claims["sid"] = "sid-1"
+	token := mintToken(t, key, claims)
+
+	serve(router, "GET", "/api", token, map[string]string{ req.OnBehalfOf: "alice" })
+	serve(router, "GET", "/api", token, nil)
+
+	if stats := oc.TokenCacheStats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	//--- Cached sessions must not leak per-request data
+
+	if len(onBehalfOf) != 2 || onBehalfOf[0] != "alice" || onBehalfOf[1] != "inventory" {
+		t.Errorf("Unexpected OnBehalfOf values: %v", onBehalfOf)
+	}
+
+	if rr := serve(router, "GET", "/admin", token, nil); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d but got %d", http.StatusForbidden, rr.Code)
+	}
+
+	//--- Cached sessions are still subject to revocation
+
+	_ = oc.Revocations().RevokeSession("sid-1")
+	if rr := serve(router, "GET", "/api", token, nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d but got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+//=============================================================================
+
+func benchmarkSecure(b *testing.B, cacheSize int) {
+	gin.SetMode(gin.TestMode)
+
+	key := newTestKey(b)
+	oc  := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	oc.SetTokenCache(cacheSize)
+
+	router := gin.New()
+	router.GET("/api", oc.Secure(func(c *Context) {
+		c.Gin.Status(http.StatusOK)
+	}, roles.User))
+
+	token := mintToken(b, key, userClaims("bob", role.User))
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rq := httptest.NewRequest("GET", "/api", nil)
+			rq.Header.Set("Authorization", "Bearer "+ token)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, rq)
+
+			if rr.Code != http.StatusOK {
+				b.Fatalf("Unexpected status: %d", rr.Code)
+			}
+		}
+	})
+}
+
+//=============================================================================
+
+func BenchmarkSecureWithoutCache(b *testing.B) {
+	benchmarkSecure(b, 0)
+}
+
+//=============================================================================
+
+func BenchmarkSecureWithCache(b *testing.B) {
+	benchmarkSecure(b, DefaultCacheSize)
+}
+
+//=============================================================================
@@ -0,0 +1,140 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/bit-fever/core/req"
+	"net/http"
+)
+
+//=============================================================================
+
+type Caller struct {
+	ctx        context.Context
+	token      func() (string, error)
+	onBehalfOf string
+}
+
+//=============================================================================
+//===
+//=== Context methods
+//===
+//=============================================================================
+//--- Calls other services with the caller's own token. Sessions without a
+//--- bearer token (certificate, API key, development) cannot be forwarded
+
+func (c *Context) Forward() *Caller {
+	token := c.Token
+
+	ca := &Caller{
+		ctx  : c.Ctx,
+		token: func() (string, error) {
+			if token == "" {
+				return "", errors.New("cannot forward the caller's identity: the request has no bearer token")
+			}
+			return token, nil
+		},
+	}
+
+	if c.Session.IsDelegated() {
+		ca.onBehalfOf = c.Session.OnBehalfOf
+	}
+
+	return ca
+}
+
+//=============================================================================
+//--- Calls other services with the service token, on behalf of the current user
+
+func (c *Context) AsService() *Caller {
+	return &Caller{
+		ctx       : c.Ctx,
+		token     : Token,
+		onBehalfOf: c.Session.OnBehalfOf,
+	}
+}
+
+//=============================================================================
+//--- Same as AsService, with the token of a named profile
+
+func (c *Context) AsServiceProfile(profile string) *Caller {
+	return &Caller{
+		ctx       : c.Ctx,
+		token     : func() (string, error) { return TokenFor(profile) },
+		onBehalfOf: c.Session.OnBehalfOf,
+	}
+}
+
+//=============================================================================
+//===
+//=== Caller methods
+//===
+//=============================================================================
+
+func (ca *Caller) Get(client *http.Client, url string, output any) error {
+	return ca.do(client, "GET", url, nil, output)
+}
+
+//=============================================================================
+
+func (ca *Caller) Post(client *http.Client, url string, params any, output any) error {
+	return ca.do(client, "POST", url, params, output)
+}
+
+//=============================================================================
+
+func (ca *Caller) Put(client *http.Client, url string, params any, output any) error {
+	return ca.do(client, "PUT", url, params, output)
+}
+
+//=============================================================================
+
+func (ca *Caller) Delete(client *http.Client, url string, params any, output any) error {
+	return ca.do(client, "DELETE", url, params, output)
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (ca *Caller) do(client *http.Client, method string, url string, params any, output any) error {
+	token, err := ca.token()
+	if err != nil {
+		return err
+	}
+
+	ctx := ca.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return req.DoRequest(ctx, client, method, url, params, output, token, ca.onBehalfOf)
+}
+
+//=============================================================================
@@ -0,0 +1,187 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/bit-fever/core/req"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+type echoedHeaders struct {
+	Authorization string `json:"authorization"`
+	OnBehalfOf    string `json:"onBehalfOf"`
+}
+
+//=============================================================================
+
+func newEchoServer(t *testing.T, delay time.Duration) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+
+		w.Header().Set("Content-Type", req.ApplicationJson)
+		_ = json.NewEncoder(w).Encode(&echoedHeaders{
+			Authorization: r.Header.Get("Authorization"),
+			OnBehalfOf   : r.Header.Get(req.OnBehalfOf),
+		})
+	}))
+
+	t.Cleanup(server.Close)
+	return server
+}
+
+//=============================================================================
+
+func TestContextCallers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key     := newTestKey(t)
+	oc      := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	backend := newEchoServer(t, 0)
+
+	setTokenProfile(t, &RestContext{
+		name         : DefaultProfile,
+		tokenResponse: &TokenResponse{ AccessToken: "service-token", ExpiresIn: 300 },
+		validUntil   : time.Now().Add(time.Minute),
+	})
+
+	var forwarded, asService echoedHeaders
+	router := gin.New()
+	router.GET("/api", oc.Secure(func(c *Context) {
+		if err := c.Forward().Get(backend.Client(), backend.URL, &forwarded); err != nil {
+			c.ReturnError(err)
+			return
+		}
+		if err := c.AsService().Get(backend.Client(), backend.URL, &asService); err != nil {
+			c.ReturnError(err)
+			return
+		}
+		_ = c.ReturnObject("ok")
+	}, roles.Admin_User_Service))
+
+	token := mintToken(t, key, userClaims("inventory", role.Service))
+
+	if rr := serve(router, "GET", "/api", token, map[string]string{ req.OnBehalfOf: "alice" }); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	if forwarded.Authorization != "Bearer "+ token || forwarded.OnBehalfOf != "alice" {
+		t.Errorf("Unexpected forwarded headers: %+v", forwarded)
+	}
+
+	if asService.Authorization != "Bearer service-token" || asService.OnBehalfOf != "alice" {
+		t.Errorf("Unexpected service headers: %+v", asService)
+	}
+}
+
+//=============================================================================
+
+func TestContextDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key     := newTestKey(t)
+	oc      := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	backend := newEchoServer(t, time.Second)
+
+	var hasDeadline bool
+	var callErr     error
+
+	router := gin.New()
+	router.GET("/api", oc.Secure(func(c *Context) {
+		_, hasDeadline = c.Ctx.Deadline()
+
+		var out echoedHeaders
+		callErr = c.Forward().Get(backend.Client(), backend.URL, &out)
+		c.Gin.Status(http.StatusOK)
+	}, roles.User, WithTimeout(50 * time.Millisecond)))
+
+	start := time.Now()
+	serve(router, "GET", "/api", mintToken(t, key, userClaims("alice", role.User)), nil)
+
+	if !hasDeadline {
+		t.Errorf("Expected the request context to have a deadline")
+	}
+
+	if !errors.Is(callErr, context.DeadlineExceeded) {
+		t.Errorf("Expected a deadline error but got %v", callErr)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500 * time.Millisecond {
+		t.Errorf("The downstream call was not cancelled: %v", elapsed)
+	}
+
+	//--- Timeouts are opt-in per route
+
+	router.GET("/long", oc.Secure(func(c *Context) {
+		_, hasDeadline = c.Ctx.Deadline()
+		c.Gin.Status(http.StatusOK)
+	}, roles.User))
+
+	serve(router, "GET", "/long", mintToken(t, key, userClaims("alice", role.User)), nil)
+	if hasDeadline {
+		t.Errorf("Expected no deadline without WithTimeout")
+	}
+}
+
+//=============================================================================
+
+func TestForwardWithoutBearer(t *testing.T) {
+	var hits atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	t.Cleanup(backend.Close)
+
+	c := &Context{
+		Ctx    : context.Background(),
+		Session: &UserSession{ Username: "scheduler", OnBehalfOf: "scheduler", AuthMethod: AuthMethodApiKey },
+	}
+
+	var out echoedHeaders
+	if err := c.Forward().Get(backend.Client(), backend.URL, &out); err == nil {
+		t.Errorf("Expected an error when forwarding a session without a bearer token")
+	}
+
+	if n := hits.Load(); n != 0 {
+		t.Errorf("The request must not be sent, but the backend was called %d times", n)
+	}
+}
+
+//=============================================================================
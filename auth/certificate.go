@@ -0,0 +1,166 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"crypto/x509"
+	"errors"
+	"github.com/bit-fever/core"
+	"github.com/gin-gonic/gin"
+)
+
+//=============================================================================
+
+type CertificateMode int
+
+const (
+	CertificateIgnored CertificateMode = iota
+	CertificateAllowed
+	CertificateRequired
+)
+
+//=============================================================================
+
+type CertificateIdentity struct {
+	CommonName          string
+	OrganizationalUnits []string
+	DNSNames            []string
+	EmailAddresses      []string
+	URIs                []string
+	SerialNumber        string
+}
+
+//=============================================================================
+
+type CertificateAuthenticator struct {
+	mapper *ClaimMapper
+}
+
+//=============================================================================
+//===
+//=== Public functions
+//===
+//=============================================================================
+
+func NewCertificateAuthenticator(cfg *core.ClaimMapping) *CertificateAuthenticator {
+	var rules []core.RoleMapping
+	if cfg != nil {
+		rules = cfg.Roles
+	}
+
+	return &CertificateAuthenticator{
+		mapper: &ClaimMapper{ rules: rules },
+	}
+}
+
+//=============================================================================
+
+func (ca *CertificateAuthenticator) Authenticate(cert *x509.Certificate) (*UserSession, error) {
+	ci := NewCertificateIdentity(cert)
+
+	username := ci.username()
+	if username == "" {
+		return nil, errors.New("client certificate has no usable subject")
+	}
+
+	return &UserSession{
+		Username   : username,
+		OnBehalfOf : username,
+		IssuedAt   : cert.NotBefore,
+		Expiry     : cert.NotAfter,
+		Roles      : ca.mapper.mapRoles(ci.claims()),
+		AuthMethod : AuthMethodCertificate,
+		Certificate: ci,
+	}, nil
+}
+
+//=============================================================================
+
+func NewCertificateIdentity(cert *x509.Certificate) *CertificateIdentity {
+	ci := &CertificateIdentity{
+		CommonName         : cert.Subject.CommonName,
+		OrganizationalUnits: cert.Subject.OrganizationalUnit,
+		DNSNames           : cert.DNSNames,
+		EmailAddresses     : cert.EmailAddresses,
+		SerialNumber       : cert.SerialNumber.String(),
+	}
+
+	for _, u := range cert.URIs {
+		ci.URIs = append(ci.URIs, u.String())
+	}
+
+	return ci
+}
+
+//=============================================================================
+
+func PeerCertificate(c *gin.Context) *x509.Certificate {
+	state := c.Request.TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	return state.VerifiedChains[0][0]
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (ci *CertificateIdentity) claims() map[string]any {
+	return map[string]any{
+		"cn"   : ci.CommonName,
+		"ou"   : toAnySlice(ci.OrganizationalUnits),
+		"dns"  : toAnySlice(ci.DNSNames),
+		"email": toAnySlice(ci.EmailAddresses),
+		"uri"  : toAnySlice(ci.URIs),
+	}
+}
+
+//=============================================================================
+//--- The common name, or the first DNS name
+
+func (ci *CertificateIdentity) username() string {
+	if ci.CommonName == "" && len(ci.DNSNames) > 0 {
+		return ci.DNSNames[0]
+	}
+
+	return ci.CommonName
+}
+
+//=============================================================================
+
+func toAnySlice(values []string) []any {
+	res := make([]any, len(values))
+	for i, v := range values {
+		res[i] = v
+	}
+
+	return res
+}
+
+//=============================================================================
@@ -0,0 +1,180 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/bit-fever/core/req"
+	"github.com/gin-gonic/gin"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+func newTestCertificate(t *testing.T, cn string, ou ...string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Cannot generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject     : pkix.Name{ CommonName: cn, OrganizationalUnit: ou },
+		DNSNames    : []string{ cn +".bit-fever.local" },
+		NotBefore   : time.Now().Add(-time.Hour),
+		NotAfter    : time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Cannot create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Cannot parse certificate: %v", err)
+	}
+
+	return cert
+}
+
+//=============================================================================
+
+func serveTLS(router *gin.Engine, path string, token string, cert *x509.Certificate) *httptest.ResponseRecorder {
+	rq := httptest.NewRequest("GET", path, nil)
+	if token != "" {
+		rq.Header.Set("Authorization", "Bearer "+ token)
+	}
+
+	rq.TLS = &tls.ConnectionState{}
+	if cert != nil {
+		rq.TLS.VerifiedChains = [][]*x509.Certificate{{ cert }}
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, rq)
+
+	return rr
+}
+
+//=============================================================================
+
+func TestCertificateAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key := newTestKey(t)
+	oc  := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	oc.SetCertificateAuthentication(NewCertificateAuthenticator(&core.ClaimMapping{
+		Roles: []core.RoleMapping{
+			{ Claim: "ou", Value: "services", Role: string(role.Service) },
+		},
+	}), CertificateIgnored)
+
+	apiKey, apiHash, _ := GenerateApiKey()
+	store, err := NewConfigApiKeyStore([]core.ApiKey{
+		{ Id: "k1", Hash: apiHash, Owner: "scheduler", Roles: []string{ "user" }},
+	})
+	if err != nil {
+		t.Fatalf("NewConfigApiKeyStore failed: %v", err)
+	}
+	oc.SetApiKeyAuthentication(NewApiKeyAuthenticator(store), true)
+
+	var session *UserSession
+	handler := func(c *Context) {
+		session = c.Session
+		_ = c.ReturnObject("ok")
+	}
+
+	router := gin.New()
+	router.GET("/bearer",   oc.Secure(handler, roles.Service))
+	router.GET("/allowed",  oc.Secure(handler, roles.Service, WithCertificate(CertificateAllowed)))
+	router.GET("/required", oc.Secure(handler, roles.User,    WithCertificate(CertificateRequired)))
+
+	service := newTestCertificate(t, "inventory", "services")
+	other   := newTestCertificate(t, "laptop",    "staff")
+	token   := mintToken(t, key, userClaims("bob", role.User))
+
+	cases := []struct {
+		path   string
+		token  string
+		cert   *x509.Certificate
+		status int
+		user   string
+	}{
+		{ "/bearer",   "",    service, http.StatusUnauthorized, ""          },
+		{ "/allowed",  "",    service, http.StatusOK,           "inventory" },
+		{ "/allowed",  "",    other,   http.StatusForbidden,    ""          },
+		{ "/allowed",  "",    nil,     http.StatusUnauthorized, ""          },
+		{ "/required", token, nil,     http.StatusUnauthorized, ""          },
+		{ "/required", "",    service, http.StatusUnauthorized, ""          },
+		{ "/required", token, other,   http.StatusOK,           "bob"       },
+	}
+
+	for i, tc := range cases {
+		session = nil
+		rr := serveTLS(router, tc.path, tc.token, tc.cert)
+
+		if rr.Code != tc.status {
+			t.Errorf("Case %d: expected status %d but got %d", i, tc.status, rr.Code)
+			continue
+		}
+
+		if tc.status == http.StatusOK && session.Username != tc.user {
+			t.Errorf("Case %d: expected user '%v' but got '%v'", i, tc.user, session.Username)
+		}
+	}
+
+	//--- The certificate is required on every authentication path
+
+	rq := httptest.NewRequest("GET", "/required", nil)
+	rq.Header.Set(req.ApiKey, apiKey)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, rq)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d but got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	//--- The second factor identity is attached to the session
+
+	serveTLS(router, "/required", token, other)
+	if session == nil || session.Certificate == nil || session.Certificate.CommonName != "laptop" {
+		t.Errorf("Expected the certificate identity in the session")
+	}
+}
+
+//=============================================================================
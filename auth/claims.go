@@ -0,0 +1,210 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"encoding/json"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"strings"
+)
+
+//=============================================================================
+
+type ClaimMapper struct {
+	rules    []core.RoleMapping
+	username string
+	email    string
+	name     string
+	surname  string
+}
+
+//=============================================================================
+
+var DefaultClaimMapper = &ClaimMapper{
+	rules: []core.RoleMapping{
+		{ Claim: "realm_access.roles" },
+	},
+}
+
+//=============================================================================
+//===
+//=== Public functions
+//===
+//=============================================================================
+
+func NewClaimMapper(cfg *core.ClaimMapping) *ClaimMapper {
+	if cfg == nil {
+		return DefaultClaimMapper
+	}
+
+	cm := &ClaimMapper{
+		rules   : cfg.Roles,
+		username: cfg.Username,
+		email   : cfg.Email,
+		name    : cfg.Name,
+		surname : cfg.Surname,
+	}
+
+	if len(cm.rules) == 0 {
+		cm.rules = DefaultClaimMapper.rules
+	}
+
+	return cm
+}
+
+//=============================================================================
+
+func (cm *ClaimMapper) MapRoles(claims json.RawMessage) (map[role.Role]any, error) {
+	var data map[string]any
+	if err := json.Unmarshal(claims, &data); err != nil {
+		return nil, err
+	}
+
+	return cm.mapRoles(data), nil
+}
+
+//=============================================================================
+
+func GetClaimValues(data map[string]any, path string) []string {
+	return toStringValues(getClaim(data, path))
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (cm *ClaimMapper) mapRoles(data map[string]any) map[role.Role]any {
+	userRoles := map[role.Role]any{}
+
+	for _, rule := range cm.rules {
+		values := GetClaimValues(data, rule.Claim)
+		if rule.Split {
+			values = splitValues(values)
+		}
+
+		for _, value := range values {
+			if rule.Value == "" {
+				if rule.Role == "" {
+					userRoles[role.Role(value)] = nil
+				} else {
+					userRoles[role.Role(rule.Role)] = nil
+				}
+			} else if rule.Value == value {
+				userRoles[role.Role(rule.Role)] = nil
+			}
+		}
+	}
+
+	return userRoles
+}
+
+//=============================================================================
+//--- Overrides the user's identity with the configured claims, then maps roles
+
+func (cm *ClaimMapper) mapClaims(claims json.RawMessage, ut *userToken) (map[role.Role]any, error) {
+	var data map[string]any
+	if err := json.Unmarshal(claims, &data); err != nil {
+		return nil, err
+	}
+
+	mapClaim(data, cm.username, &ut.Username)
+	mapClaim(data, cm.email,    &ut.Email)
+	mapClaim(data, cm.name,     &ut.Name)
+	mapClaim(data, cm.surname,  &ut.Surname)
+
+	return cm.mapRoles(data), nil
+}
+
+//=============================================================================
+
+func mapClaim(data map[string]any, path string, target *string) {
+	if path == "" {
+		return
+	}
+
+	if value, ok := getClaim(data, path).(string); ok {
+		*target = value
+	}
+}
+
+//=============================================================================
+
+func getClaim(data map[string]any, path string) any {
+	var node any = data
+
+	for _, key := range strings.Split(path, ".") {
+		m, ok := node.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		node, ok = m[key]
+		if !ok {
+			return nil
+		}
+	}
+
+	return node
+}
+
+//=============================================================================
+
+func toStringValues(node any) []string {
+	switch value := node.(type) {
+	case string:
+		if value == "" {
+			return nil
+		}
+		return []string{ value }
+
+	case []any:
+		var values []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+//=============================================================================
+//--- Space separated claims (like 'scope') are split into single values
+
+func splitValues(values []string) []string {
+	var fields []string
+	for _, v := range values {
+		fields = append(fields, strings.Fields(v)...)
+	}
+
+	return fields
+}
+
+//=============================================================================
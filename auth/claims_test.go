@@ -0,0 +1,112 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"testing"
+)
+
+//=============================================================================
+
+const testClaims = `{
+	"preferred_username": "alice",
+	"realm_access"      : { "roles": [ "user", "offline_access" ] },
+	"resource_access"   : { "bf-portfolio": { "roles": [ "portfolio-admin" ] } },
+	"groups"            : [ "/traders", "/ops" ],
+	"scope"             : "openid profile bf:automation"
+}`
+
+//=============================================================================
+
+func TestDefaultClaimMapper(t *testing.T) {
+	userRoles, err := DefaultClaimMapper.MapRoles([]byte(testClaims))
+	if err != nil {
+		t.Fatalf("MapRoles failed: %v", err)
+	}
+
+	if len(userRoles) != 2 {
+		t.Errorf("Expected 2 roles but got %v", userRoles)
+	}
+
+	if _, ok := userRoles[role.User]; !ok {
+		t.Errorf("Expected role '%v' in %v", role.User, userRoles)
+	}
+}
+
+//=============================================================================
+
+func TestConfiguredClaimMapper(t *testing.T) {
+	cm := NewClaimMapper(&core.ClaimMapping{
+		Roles: []core.RoleMapping{
+			{ Claim: "resource_access.bf-portfolio.roles", Value: "portfolio-admin", Role: "admin"   },
+			{ Claim: "groups",                             Value: "/traders",        Role: "user"    },
+			{ Claim: "scope",                              Value: "bf:automation",   Role: "service", Split: true },
+			{ Claim: "groups",                             Value: "/missing",        Role: "other"   },
+		},
+	})
+
+	userRoles, err := cm.MapRoles([]byte(testClaims))
+	if err != nil {
+		t.Fatalf("MapRoles failed: %v", err)
+	}
+
+	expected := []role.Role{ role.Admin, role.User, role.Service }
+	if len(userRoles) != len(expected) {
+		t.Errorf("Expected roles %v but got %v", expected, userRoles)
+	}
+
+	for _, r := range expected {
+		if _, ok := userRoles[r]; !ok {
+			t.Errorf("Expected role '%v' in %v", r, userRoles)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestClaimValuesAreNotSplit(t *testing.T) {
+	data := map[string]any{ "dept": "admin team" }
+
+	if values := GetClaimValues(data, "dept"); len(values) != 1 || values[0] != "admin team" {
+		t.Errorf("Unexpected values: %v", values)
+	}
+
+	cm := NewClaimMapper(&core.ClaimMapping{
+		Roles: []core.RoleMapping{ { Claim: "dept" } },
+	})
+
+	userRoles, err := cm.MapRoles([]byte(`{"dept":"admin team"}`))
+	if err != nil {
+		t.Fatalf("MapRoles failed: %v", err)
+	}
+
+	if _, ok := userRoles[role.Admin]; ok || len(userRoles) != 1 {
+		t.Errorf("Unexpected roles: %v", userRoles)
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,267 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+//=============================================================================
+
+const (
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+	AuthMethodPrivateKeyJwt     = "private_key_jwt"
+)
+
+//=============================================================================
+
+const (
+	ClientAssertionType     = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	clientAssertionLifetime = time.Minute
+)
+
+//=============================================================================
+//--- Error returned by the token endpoint (RFC 6749, section 5.2)
+
+type TokenError struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	Uri         string `json:"error_uri"`
+}
+
+//=============================================================================
+
+func (e *TokenError) Error() string {
+	switch {
+	case e.Code == "":
+		return fmt.Sprintf("token endpoint returned status %d", e.StatusCode)
+	case e.Description == "":
+		return "token endpoint error: "+ e.Code
+	}
+
+	return "token endpoint error: "+ e.Code +" ("+ e.Description +")"
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func newClientSigner(profile string, authMethod string, keyFile string, keyId string) (jose.Signer, error) {
+	switch authMethod {
+	case AuthMethodClientSecretBasic, AuthMethodClientSecretPost:
+		return nil, nil
+
+	case AuthMethodPrivateKeyJwt:
+		if keyFile == "" {
+			return nil, errors.New("token profile '"+ profile +"': private_key_jwt requires a private key")
+		}
+
+	default:
+		return nil, errors.New("token profile '"+ profile +"': unsupported authentication method: "+ authMethod)
+	}
+
+	key, err := readPrivateKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	alg, err := signingAlgorithm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	options := (&jose.SignerOptions{}).WithType("JWT")
+	if keyId != "" {
+		options = options.WithHeader("kid", keyId)
+	}
+
+	return jose.NewSigner(jose.SigningKey{ Algorithm: alg, Key: key }, options)
+}
+
+//=============================================================================
+//--- Adds the client credentials to the form, as configured in the profile.
+//--- client_secret_basic credentials travel in the header (see setBasicAuth)
+
+func (rc *RestContext) authenticate(params url.Values) error {
+	switch rc.authMethod {
+	case AuthMethodClientSecretPost:
+		params.Set("client_id",     rc.clientId)
+		params.Set("client_secret", rc.clientSecret)
+
+	case AuthMethodPrivateKeyJwt:
+		assertion, err := rc.clientAssertion()
+		if err != nil {
+			return err
+		}
+
+		params.Set("client_id",             rc.clientId)
+		params.Set("client_assertion_type", ClientAssertionType)
+		params.Set("client_assertion",      assertion)
+	}
+
+	return nil
+}
+
+//=============================================================================
+
+func (rc *RestContext) setBasicAuth(rq *http.Request) {
+	if rc.authMethod == AuthMethodClientSecretBasic {
+		//--- RFC 6749 requires both values to be form-encoded before base64
+		rq.SetBasicAuth(url.QueryEscape(rc.clientId), url.QueryEscape(rc.clientSecret))
+	}
+}
+
+//=============================================================================
+
+func (rc *RestContext) clientAssertion() (string, error) {
+	jti, err := newTicket()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	claims := jwt.Claims{
+		Issuer   : rc.clientId,
+		Subject  : rc.clientId,
+		Audience : jwt.Audience{ rc.tokenUrl },
+		ID       : jti,
+		IssuedAt : jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Expiry   : jwt.NewNumericDate(now.Add(clientAssertionLifetime)),
+	}
+
+	return jwt.Signed(rc.signer).Claims(claims).Serialize()
+}
+
+//=============================================================================
+
+func readTokenResponse(res *http.Response) (*TokenResponse, error) {
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 400 {
+		te := &TokenError{}
+		_   = json.Unmarshal(body, te)
+		te.StatusCode = res.StatusCode
+
+		return nil, te
+	}
+
+	resp := &TokenResponse{}
+	if err = json.Unmarshal(body, resp); err != nil {
+		return nil, err
+	}
+
+	if resp.AccessToken == "" {
+		return nil, errors.New("token endpoint returned no access token")
+	}
+
+	return resp, nil
+}
+
+//=============================================================================
+
+func readPrivateKey(pemFile string) (crypto.Signer, error) {
+	data, err := os.ReadFile(pemFile)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found in file: "+ pemFile)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+	}
+
+	return nil, errors.New("unsupported PEM block '"+ block.Type +"' in file: "+ pemFile)
+}
+
+//=============================================================================
+
+func signingAlgorithm(key crypto.Signer) (jose.SignatureAlgorithm, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return jose.RS256, nil
+
+	case ed25519.PrivateKey:
+		return jose.EdDSA, nil
+
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jose.ES256, nil
+		case elliptic.P384():
+			return jose.ES384, nil
+		case elliptic.P521():
+			return jose.ES512, nil
+		}
+	}
+
+	return "", errors.New("unsupported private key type")
+}
+
+//=============================================================================
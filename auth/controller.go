@@ -26,26 +26,43 @@ package auth
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/x509"
+	"errors"
 	"github.com/bit-fever/core"
 	"github.com/bit-fever/core/auth/role"
 	"github.com/bit-fever/core/req"
-	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/gin-gonic/gin"
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 )
 
 //=============================================================================
 
 type OidcController struct {
-	authority string
-	client    *http.Client
-	context   *context.Context
-	verifier  *oidc.IDTokenVerifier
-	logger    *slog.Logger
-	config    any
+	verifier   TokenVerifier
+	issuers    map[string]*trustedIssuer
+	mapper     *ClaimMapper
+	permModel  *PermissionModel
+	delegation *DelegationPolicy
+	certAuth   *CertificateAuthenticator
+	certMode   CertificateMode
+	apiKeyAuth *ApiKeyAuthenticator
+	apiKeyOn   bool
+	revocation *RevocationRegistry
+	enrichment *SessionEnrichment
+	validation *core.TokenValidation
+	tokenCache *expiringCache[*UserSession]
+	limiter    *RateLimiter
+	audit      *AuditRecorder
+	auditOn    bool
+	tickets    *expiringCache[*streamTicket]
+	ticketTTL  time.Duration
+	streams    *streamRegistry
+	devUser    *UserSession
+	logger     *slog.Logger
+	config     any
 }
 
 //=============================================================================
@@ -57,128 +74,438 @@ type userToken struct {
 	Surname  string `json:"family_name,omitempty"`
 	Username string `json:"preferred_username,omitempty"`
 	Email    string `json:"email,omitempty"`
-
-	RealmAccess map[string]json.RawMessage `json:"realm_access,omitempty"`
+	Azp      string `json:"azp,omitempty"`
+	Scope    string `json:"scope,omitempty"`
 }
 
 //=============================================================================
 
 func NewOidcController(authority string, client *http.Client, logger *slog.Logger, config any) *OidcController {
-	ccontext      := oidc.ClientContext(context.Background(), client)
-	provider, err := oidc.NewProvider(ccontext, authority)
+	verifier, err := NewDiscoveryVerifier(authority, client)
 	core.ExitIfError(err)
 
-	oidcConfig := &oidc.Config{
-		SkipClientIDCheck: true,
+	return NewOidcControllerWithVerifier(verifier, logger, config)
+}
+
+//=============================================================================
+
+func NewOidcControllerWithVerifier(verifier TokenVerifier, logger *slog.Logger, config any) *OidcController {
+	oc := &OidcController{
+		verifier  : verifier,
+		mapper    : DefaultClaimMapper,
+		permModel : EmptyPermissionModel,
+		delegation: DefaultDelegationPolicy,
+		revocation: NewRevocationRegistry(DefaultRevocationTTL),
+		tokenCache: newExpiringCache[*UserSession](DefaultCacheSize),
+		limiter   : newRateLimiter(),
+		tickets   : newExpiringCache[*streamTicket](DefaultCacheSize),
+		ticketTTL : DefaultTicketTTL,
+		streams   : newStreamRegistry(),
+		logger    : logger,
+		config    : config,
+	}
+
+	oc.streams.watch(oc.revocation)
+	return oc
+}
+
+//=============================================================================
+
+func (oc *OidcController) SetClaimMapping(cfg *core.ClaimMapping) {
+	oc.mapper = NewClaimMapper(cfg)
+}
+
+//=============================================================================
+
+func (oc *OidcController) SetPermissionModel(model *PermissionModel) {
+	oc.permModel = model
+}
+
+//=============================================================================
+
+func (oc *OidcController) SetDelegationPolicy(policy *DelegationPolicy) {
+	oc.delegation = policy
+}
+
+//=============================================================================
+
+func (oc *OidcController) SetCertificateAuthentication(ca *CertificateAuthenticator, mode CertificateMode) {
+	oc.certAuth = ca
+	oc.certMode = mode
+}
+
+//=============================================================================
+
+func (oc *OidcController) SetApiKeyAuthentication(ak *ApiKeyAuthenticator, enabled bool) {
+	oc.apiKeyAuth = ak
+	oc.apiKeyOn   = enabled
+}
+
+//=============================================================================
+
+func (oc *OidcController) SetRevocationRegistry(rr *RevocationRegistry) {
+	if rr == nil {
+		core.ExitWithMessage("The revocation registry cannot be nil")
+	}
+
+	oc.revocation = rr
+	oc.streams.watch(rr)
+}
+
+//=============================================================================
+
+func (oc *OidcController) SetSessionEnrichment(se *SessionEnrichment) {
+	oc.enrichment = se
+}
+
+//=============================================================================
+
+func (oc *OidcController) SetTokenValidation(rules *core.TokenValidation) {
+	oc.validation = rules
+}
+
+//=============================================================================
+
+func (oc *OidcController) SetTokenCache(maxSize int) {
+	if maxSize <= 0 {
+		oc.tokenCache = nil
+	} else {
+		oc.tokenCache = newExpiringCache[*UserSession](maxSize)
 	}
-	verifier := provider.Verifier(oidcConfig)
+}
+
+//=============================================================================
+
+func (oc *OidcController) TokenCacheStats() CacheStats {
+	if oc.tokenCache == nil {
+		return CacheStats{}
+	}
+
+	return oc.tokenCache.Stats()
+}
+
+//=============================================================================
 
-	return &OidcController{
-		authority: authority,
-		client   : client,
-		context  : &ccontext,
-		verifier : verifier,
-		logger   : logger,
-		config   : config,
+func (oc *OidcController) SetRateLimiting(cfg *core.RateLimiting) error {
+	limiter, err := NewRateLimiter(cfg)
+	if err != nil {
+		return err
 	}
+
+	oc.limiter = limiter
+	return nil
+}
+
+//=============================================================================
+
+func (oc *OidcController) SetAuditRecorder(ar *AuditRecorder, enabled bool) {
+	oc.audit   = ar
+	oc.auditOn = enabled
+}
+
+//=============================================================================
+
+func (oc *OidcController) Revocations() *RevocationRegistry {
+	return oc.revocation
+}
+
+//=============================================================================
+
+func (oc *OidcController) Secure(h RestService, roles []role.Role, options ...RouteOption) func(c *gin.Context) {
+	rc := oc.newRouteConfig(options)
+	rc.roles     = roles
+	rc.roleCheck = true
+
+	return oc.secure(h, rc)
 }
 
 //=============================================================================
 
-func (oc *OidcController) Secure(h RestService, roles []role.Role) func(c *gin.Context) {
+func (oc *OidcController) SecureWith(h RestService, permissions []Permission, options ...RouteOption) func(c *gin.Context) {
+	return oc.secure(h, oc.newPermissionConfig(permissions, options))
+}
+
+//=============================================================================
+//===
+//=== Private methods
+//===
+//=============================================================================
+
+func (oc *OidcController) secure(h RestService, rc *routeConfig) func(c *gin.Context) {
 	return func(c *gin.Context) {
-		rawAccessToken := c.Request.Header.Get("Authorization")
-		onBehalfOf     := c.Request.Header.Get(req.OnBehalfOf)
+		us, token, err := oc.authenticate(c, rc)
+		if err != nil {
+			req.ReturnUnauthorizedError(c, err.Error())
+			return
+		}
 
-		tokens := strings.Split(rawAccessToken, " ")
-		if len(tokens) != 2 {
-			req.ReturnUnauthorizedError(c, "Authorisation failed due to a bad header")
+		if onBehalfOf := c.Request.Header.Get(req.OnBehalfOf); onBehalfOf != "" {
+			us.OnBehalfOf = onBehalfOf
+		}
+
+		oc.serve(c, rc, us, token, h, false)
+	}
+}
+
+//=============================================================================
+//--- Runs the checks shared by Secure and SecureStream, then the handler. A
+//--- stream is registered, so that a revocation cancels its context
+
+func (oc *OidcController) serve(c *gin.Context, rc *routeConfig, us *UserSession, token string, h RestService, stream bool) {
+	var record *AuditRecord
+	if oc.auditEnabled(rc) && oc.audit != nil {
+		record = newAuditRecord(c, us)
+		defer func() {
+			record.complete(c, us)
+			oc.audit.Record(record)
+		}()
+	}
+
+	if oc.revocation.IsRevoked(us) {
+		req.ReturnUnauthorizedError(c, "Authorisation failed: the session has been revoked")
+		return
+	}
+
+	if err := oc.validate(rc, us); err != nil {
+		req.ReturnError(c, err)
+		return
+	}
+
+	us.Permissions = oc.permModel.Resolve(us.Roles)
+
+	if ! rc.isAuthorized(us) {
+		req.ReturnForbiddenError(c, "User not allowed to access this API: "+ us.Username)
+		return
+	}
+
+	if us.IsDelegated() {
+		if ! oc.delegationPolicy(rc).IsAllowed(us) {
+			req.ReturnForbiddenError(c, "User not allowed to act on behalf of another user: "+ us.Username)
 			return
 		}
 
-		idToken, err := oc.verifier.Verify(*oc.context, tokens[1])
-		if err != nil {
-			req.ReturnUnauthorizedError(c, "Authorisation failed while verifying the token: "+ err.Error())
+		oc.logger.Info("Delegated access granted",
+			slog.String("client",     c.ClientIP()),
+			slog.String("username",   us.Username),
+			slog.String("onBehalfOf", us.OnBehalfOf),
+			slog.String("method",     c.Request.Method),
+			slog.String("path",       c.FullPath()))
+	}
+
+	route := c.Request.Method +" "+ c.FullPath()
+	if ok, wait := oc.limiter.Allow(us, c.RemoteIP(), route, rc.rateLimit); !ok {
+		req.ReturnTooManyRequestsError(c, wait, "Too many requests for user: "+ us.Username)
+		return
+	}
+
+	var rctx context.Context
+	var cancel context.CancelFunc
+
+	if stream {
+		rctx, cancel = newStreamContext(c, us)
+	} else {
+		rctx, cancel = newRequestContext(c, rc.timeout)
+	}
+	defer cancel()
+
+	if stream {
+		conn := &streamConn{ session: us, cancel: cancel }
+		oc.streams.add(conn)
+		defer oc.streams.remove(conn)
+
+		//--- A revocation applied before the registration would be missed
+
+		if oc.revocation.IsRevoked(us) {
+			req.ReturnUnauthorizedError(c, "Authorisation failed: the session has been revoked")
 			return
 		}
+	}
 
-		var ut userToken
-		if err := idToken.Claims(&ut); err != nil {
-			req.ReturnUnauthorizedError(c, "Authorization failed while getting claims: "+ err.Error())
+	if oc.enrichment != nil {
+		if err := oc.enrichment.Enrich(rctx, us); err != nil {
+			oc.logger.Error("Cannot enrich the user session", slog.String("username", us.OnBehalfOf), slog.String("error", err.Error()))
+			req.ReturnError(c, req.NewServiceUnavailableError("Cannot load the user's profile: %v", us.OnBehalfOf))
 			return
 		}
+	}
 
-		us := buildUserSession(&ut, idToken, onBehalfOf)
+	ctx := &Context{
+		Ctx       : rctx,
+		Gin       : c,
+		Session   : us,
+		Log       : oc.createLogger(us, c),
+		Config    : oc.config,
+		Token     : token,
+		audit     : record,
+		revocation: oc.revocation,
+	}
 
-		if ! us.IsUserInRole(roles) {
-			req.ReturnForbiddenError(c, "User not allowed to access this API: "+ us.Username)
-			return
+	h(ctx)
+}
+
+//=============================================================================
+
+func (oc *OidcController) authenticate(c *gin.Context, rc *routeConfig) (*UserSession, string, error) {
+	cert := PeerCertificate(c)
+	mode := oc.certificateMode(rc)
+
+	if mode == CertificateRequired && cert == nil {
+		return nil, "", errors.New("Authorisation failed: a client certificate is required")
+	}
+
+	us, token, err := oc.authenticateCaller(c, rc, cert)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if mode == CertificateRequired && us.Certificate == nil {
+		us.Certificate = NewCertificateIdentity(cert)
+	}
+
+	return us, token, nil
+}
+
+//=============================================================================
+
+func (oc *OidcController) authenticateCaller(c *gin.Context, rc *routeConfig, cert *x509.Certificate) (*UserSession, string, error) {
+	header := c.Request.Header.Get("Authorization")
+
+	if oc.apiKeyEnabled(rc) && header == "" {
+		if key := c.Request.Header.Get(req.ApiKey); key != "" {
+			if oc.apiKeyAuth == nil {
+				return nil, "", errors.New("Authorisation failed: API key authentication is not configured")
+			}
+
+			//--- The peer address, as X-Forwarded-For is set by the caller
+			us, err := oc.apiKeyAuth.Authenticate(key, c.RemoteIP())
+			if err != nil {
+				return nil, "", errors.New("Authorisation failed while checking the API key: "+ err.Error())
+			}
+
+			return us, "", nil
 		}
+	}
 
-		ctx := &Context{
-			Gin    : c,
-			Session: us,
-			Log    : oc.createLogger(us, c),
-			Config : oc.config,
-			Token  : tokens[1],
+	if oc.certificateMode(rc) == CertificateAllowed && header == "" && cert != nil {
+		if oc.certAuth == nil {
+			return nil, "", errors.New("Authorisation failed: certificate authentication is not configured")
 		}
 
-		h(ctx)
+		us, err := oc.certAuth.Authenticate(cert)
+		if err != nil {
+			return nil, "", errors.New("Authorisation failed while reading the certificate: "+ err.Error())
+		}
+
+		return us, "", nil
 	}
+
+	if header == "" && oc.devUser != nil {
+		us := oc.devUser.Clone()
+		us.IssuedAt = time.Now()
+		return us, "", nil
+	}
+
+	return oc.authenticateBearer(c, header)
+}
+
+//=============================================================================
+
+func (oc *OidcController) authenticateBearer(c *gin.Context, header string) (*UserSession, string, error) {
+	tokens := strings.Split(header, " ")
+	if len(tokens) != 2 {
+		return nil, "", errors.New("Authorisation failed due to a bad header")
+	}
+
+	key := ""
+	if oc.tokenCache != nil {
+		key = hashToken(tokens[1])
+		if us, ok := oc.tokenCache.Get(key); ok {
+			return us.Clone(), tokens[1], nil
+		}
+	}
+
+	ti, err := oc.selectIssuer(tokens[1])
+	if err != nil {
+		return nil, "", errors.New("Authorisation failed: "+ err.Error())
+	}
+
+	vt, err := ti.verifier.Verify(c.Request.Context(), tokens[1])
+	if err != nil {
+		return nil, "", errors.New("Authorisation failed while verifying the token: "+ err.Error())
+	}
+
+	var ut userToken
+	if err := vt.ParseClaims(&ut); err != nil {
+		return nil, "", errors.New("Authorization failed while getting claims: "+ err.Error())
+	}
+
+	userRoles, err := ti.mapper.mapClaims(vt.Claims, &ut)
+	if err != nil {
+		return nil, "", errors.New("Authorization failed while mapping roles: "+ err.Error())
+	}
+
+	ut.Username = ti.qualify(ut.Username)
+
+	us := buildUserSession(&ut, vt, userRoles)
+	us.Realm = ti.name
+
+	if oc.tokenCache != nil {
+		oc.tokenCache.Put(key, us, us.Expiry)
+	}
+
+	return us.Clone(), tokens[1], nil
 }
 
 //=============================================================================
 
 func (oc *OidcController) createLogger(us *UserSession, c *gin.Context) *slog.Logger {
-	return oc.logger.With(
+	logger := oc.logger.With(
 		slog.String("client",   c.ClientIP()),
 		slog.String("username", us.Username),
-	).WithGroup("data")
+	)
+
+	if us.Realm != "" {
+		logger = logger.With(slog.String("realm", us.Realm))
+	}
+
+	if us.IsDelegated() {
+		logger = logger.With(slog.String("onBehalfOf", us.OnBehalfOf))
+	}
+
+	return logger.WithGroup("data")
 }
 
-//=============================================================================
-//===
-//=== Private methods
-//===
 //=============================================================================
 
-func buildUserSession(ut *userToken, it *oidc.IDToken, onBehalfOf string) *UserSession {
-	if onBehalfOf == "" {
-		onBehalfOf = ut.Username
+func newRequestContext(c *gin.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(c.Request.Context())
 	}
 
+	return context.WithTimeout(c.Request.Context(), timeout)
+}
+
+//=============================================================================
+
+func buildUserSession(ut *userToken, vt *VerifiedToken, userRoles map[role.Role]any) *UserSession {
 	return &UserSession{
 		SessionID : ut.SID,
+		TokenID   : ut.JTI,
+		Subject   : vt.Subject,
 		Username  : ut.Username,
-		OnBehalfOf: onBehalfOf,
+		OnBehalfOf: ut.Username,
 		Name      : ut.Name,
 		Surname   : ut.Surname,
 		Email     : ut.Email,
-		IssuedAt  : it.IssuedAt,
-		Expiry    : it.Expiry,
-		Roles     : buildRoleMap(ut),
+		Issuer    : vt.Issuer,
+		Audience  : vt.Audience,
+		ClientId  : ut.Azp,
+		Scopes    : strings.Fields(ut.Scope),
+		IssuedAt  : vt.IssuedAt,
+		Expiry    : vt.Expiry,
+		Roles     : userRoles,
+		AuthMethod: AuthMethodBearer,
 	}
 }
 
 //=============================================================================
-
-func buildRoleMap(ut *userToken) map[role.Role]any {
-	userRoles := map[role.Role]any{}
-
-	for k,v := range ut.RealmAccess {
-		if k == "roles" {
-			var realmRoles []string
-			err := json.Unmarshal(v, &realmRoles)
-
-			if err == nil {
-				for _, r := range realmRoles {
-					userRoles[role.Role(r)] = nil
-				}
-			}
-		}
-	}
-
-	return userRoles
-}
-
-//=============================================================================
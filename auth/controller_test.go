@@ -0,0 +1,154 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/bit-fever/core/req"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+//=============================================================================
+
+func serve(router *gin.Engine, method string, path string, token string, headers map[string]string) *httptest.ResponseRecorder {
+	rq := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		rq.Header.Set("Authorization", "Bearer "+ token)
+	}
+
+	for k, v := range headers {
+		rq.Header.Set(k, v)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, rq)
+
+	return rr
+}
+
+//=============================================================================
+
+func userClaims(username string, userRoles ...role.Role) map[string]any {
+	return map[string]any{
+		"preferred_username": username,
+		"realm_access"      : map[string]any{ "roles": userRoles },
+	}
+}
+
+//=============================================================================
+
+func TestSecureWithLocalVerifier(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key := newTestKey(t)
+	oc  := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+
+	router := gin.New()
+	router.GET("/api", oc.Secure(func(c *Context) {
+		_ = c.ReturnObject(c.Session.Username)
+	}, roles.User))
+
+	cases := []struct {
+		token  string
+		status int
+	}{
+		{ mintToken(t, key, userClaims("bob", role.User)),  http.StatusOK },
+		{ mintToken(t, key, userClaims("bob", role.Admin)), http.StatusForbidden },
+		{ mintToken(t, newTestKey(t), nil),                 http.StatusUnauthorized },
+	}
+
+	for i, tc := range cases {
+		rr := serve(router, "GET", "/api", tc.token, nil)
+		if rr.Code != tc.status {
+			t.Errorf("Case %d: expected status %d but got %d", i, tc.status, rr.Code)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestSecureDelegation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key := newTestKey(t)
+	oc  := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+
+	var onBehalfOf string
+	handler := func(c *Context) {
+		onBehalfOf = c.Session.OnBehalfOf
+		_ = c.ReturnObject(onBehalfOf)
+	}
+
+	router := gin.New()
+	router.GET("/default", oc.Secure(handler, roles.Admin_User_Service))
+	router.GET("/listed",  oc.Secure(handler, roles.Admin_User_Service, WithDelegation(&DelegationPolicy{ Users: []string{ "scheduler" }})))
+
+	cases := []struct {
+		path   string
+		token  string
+		status int
+	}{
+		{ "/default", mintToken(t, key, userClaims("mallory",   role.User)),    http.StatusForbidden },
+		{ "/default", mintToken(t, key, userClaims("mallory",   role.Admin)),   http.StatusForbidden },
+		{ "/default", mintToken(t, key, userClaims("inventory", role.Service)), http.StatusOK },
+		{ "/listed",  mintToken(t, key, userClaims("inventory", role.Service)), http.StatusForbidden },
+		{ "/listed",  mintToken(t, key, userClaims("scheduler", role.User)),    http.StatusOK },
+	}
+
+	for i, tc := range cases {
+		onBehalfOf = ""
+		rr := serve(router, "GET", tc.path, tc.token, map[string]string{ req.OnBehalfOf: "alice" })
+
+		if rr.Code != tc.status {
+			t.Errorf("Case %d: expected status %d but got %d", i, tc.status, rr.Code)
+		}
+
+		if tc.status == http.StatusOK && onBehalfOf != "alice" {
+			t.Errorf("Case %d: expected OnBehalfOf 'alice' but got '%v'", i, onBehalfOf)
+		}
+	}
+
+	//--- Acting on behalf of oneself is always allowed
+
+	rr := serve(router, "GET", "/default", mintToken(t, key, userClaims("alice", role.User)), map[string]string{ req.OnBehalfOf: "alice" })
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	//--- The controller's policy is read when the request is served
+
+	oc.SetDelegationPolicy(NoDelegationPolicy)
+
+	rr = serve(router, "GET", "/default", mintToken(t, key, userClaims("inventory", role.Service)), map[string]string{ req.OnBehalfOf: "alice" })
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d but got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+//=============================================================================
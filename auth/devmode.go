@@ -0,0 +1,188 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"log/slog"
+	"time"
+)
+
+//=============================================================================
+
+const DefaultDevUsername = "developer"
+
+//=============================================================================
+
+type devVerifier struct {
+	verifier *oidc.IDTokenVerifier
+}
+
+//=============================================================================
+
+type devToken struct {
+	Issuer   string   `json:"iss,omitempty"`
+	Subject  string   `json:"sub,omitempty"`
+	Audience audience `json:"aud,omitempty"`
+	IssuedAt int64    `json:"iat,omitempty"`
+	Expiry   int64    `json:"exp,omitempty"`
+}
+
+//=============================================================================
+//===
+//=== Public functions
+//===
+//=============================================================================
+
+func NewDevOidcController(app *core.Application, cfg *core.DevAuthentication, logger *slog.Logger, config any) *OidcController {
+	verifier, err := NewDevVerifier(app, cfg)
+	core.ExitIfError(err)
+
+	oc := NewOidcControllerWithVerifier(verifier, logger, config)
+	oc.SetTokenCache(0)
+
+	if cfg.Username != "" || len(cfg.Roles) > 0 {
+		oc.devUser = newDevIdentity(cfg)
+	}
+
+	logger.Warn("*****************************************************************")
+	logger.Warn("*** DEVELOPMENT AUTHENTICATION ENABLED: TOKENS ARE NOT TRUSTED ***")
+	logger.Warn("*****************************************************************")
+
+	if cfg.PublicKey == "" {
+		logger.Warn("Development mode: unsigned tokens are accepted without any check")
+	} else {
+		logger.Warn("Development mode: tokens signed with a local key are accepted", "publicKey", cfg.PublicKey)
+	}
+
+	if oc.devUser != nil {
+		logger.Warn("Development mode: requests without a token are authenticated as a fixed identity",
+			"username", oc.devUser.Username,
+			"roles",    cfg.Roles)
+	}
+
+	return oc
+}
+
+//=============================================================================
+
+func NewDevVerifier(app *core.Application, cfg *core.DevAuthentication) (TokenVerifier, error) {
+	if !cfg.Enabled {
+		return nil, errors.New("development authentication is not enabled")
+	}
+
+	if app.Production {
+		return nil, errors.New("development authentication cannot be enabled in production")
+	}
+
+	if cfg.PublicKey == "" {
+		return &devVerifier{}, nil
+	}
+
+	key, err := readPublicKey(cfg.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keySet := &oidc.StaticKeySet{
+		PublicKeys: []crypto.PublicKey{ key },
+	}
+
+	oidcConfig := &oidc.Config{
+		SkipClientIDCheck   : true,
+		SkipIssuerCheck     : true,
+		SupportedSigningAlgs: signingAlgorithms,
+	}
+
+	return &devVerifier{
+		verifier: oidc.NewVerifier("", keySet, oidcConfig),
+	}, nil
+}
+
+//=============================================================================
+
+func (v *devVerifier) Verify(ctx context.Context, rawToken string) (*VerifiedToken, error) {
+	if v.verifier != nil {
+		return verifyIdToken(ctx, v.verifier, rawToken)
+	}
+
+	var dt devToken
+	payload, err := decodeJwtPayload(rawToken, &dt)
+	if err != nil {
+		return nil, err
+	}
+
+	vt := &VerifiedToken{
+		Issuer  : dt.Issuer,
+		Subject : dt.Subject,
+		Audience: dt.Audience,
+		Claims  : payload,
+	}
+
+	if dt.IssuedAt != 0 {
+		vt.IssuedAt = time.Unix(dt.IssuedAt, 0)
+	}
+
+	if dt.Expiry != 0 {
+		vt.Expiry = time.Unix(dt.Expiry, 0)
+		if time.Now().After(vt.Expiry) {
+			return nil, errors.New("token is expired")
+		}
+	}
+
+	return vt, nil
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func newDevIdentity(cfg *core.DevAuthentication) *UserSession {
+	username := cfg.Username
+	if username == "" {
+		username = DefaultDevUsername
+	}
+
+	userRoles := map[role.Role]any{}
+	for _, r := range cfg.Roles {
+		userRoles[role.Role(r)] = nil
+	}
+
+	return &UserSession{
+		Username  : username,
+		OnBehalfOf: username,
+		Roles     : userRoles,
+		AuthMethod: AuthMethodBearer,
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,163 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+func unsignedToken(claims map[string]any) string {
+	header, _  := json.Marshal(map[string]any{ "alg": "none", "typ": "JWT" })
+	payload, _ := json.Marshal(claims)
+
+	return base64.RawURLEncoding.EncodeToString(header) +"."+ base64.RawURLEncoding.EncodeToString(payload) +"."
+}
+
+//=============================================================================
+
+func TestDevVerifierRefusedInProduction(t *testing.T) {
+	if _, err := NewDevVerifier(&core.Application{ Production: true }, &core.DevAuthentication{ Enabled: true }); err == nil {
+		t.Errorf("Expected development mode to be refused in production")
+	}
+
+	if _, err := NewDevVerifier(&core.Application{}, &core.DevAuthentication{}); err == nil {
+		t.Errorf("Expected an error when development mode is not enabled")
+	}
+}
+
+//=============================================================================
+
+func TestDevModeUnsignedTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	oc := NewDevOidcController(&core.Application{}, &core.DevAuthentication{ Enabled: true }, newTestLogger(), nil)
+
+	var username string
+	router := gin.New()
+	router.GET("/api", oc.Secure(func(c *Context) {
+		username = c.Session.Username
+		_ = c.ReturnObject("ok")
+	}, roles.User))
+
+	valid := userClaims("alice", role.User)
+	valid["exp"] = time.Now().Add(time.Hour).Unix()
+
+	expired := userClaims("alice", role.User)
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	cases := []struct {
+		token  string
+		status int
+	}{
+		{ unsignedToken(valid),                                        http.StatusOK           },
+		{ mintToken(t, newTestKey(t), userClaims("alice", role.User)), http.StatusOK           },
+		{ unsignedToken(userClaims("alice", role.Admin)),              http.StatusForbidden    },
+		{ unsignedToken(expired),                                      http.StatusUnauthorized },
+		{ "garbage",                                                   http.StatusUnauthorized },
+		{ "",                                                          http.StatusUnauthorized },
+	}
+
+	for i, tc := range cases {
+		if rr := serve(router, "GET", "/api", tc.token, nil); rr.Code != tc.status {
+			t.Errorf("Case %d: expected status %d but got %d", i, tc.status, rr.Code)
+		}
+	}
+
+	if username != "alice" {
+		t.Errorf("Unexpected username: %s", username)
+	}
+}
+
+//=============================================================================
+
+func TestDevModeLocalKeyAndFixedIdentity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key := newTestKey(t)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("Cannot marshal the public key: %v", err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "dev.pem")
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{ Type: "PUBLIC KEY", Bytes: der }), 0600); err != nil {
+		t.Fatalf("Cannot write the public key: %v", err)
+	}
+
+	oc := NewDevOidcController(&core.Application{}, &core.DevAuthentication{
+		Enabled  : true,
+		Username : "dev",
+		Roles    : []string{ string(role.Admin) },
+		PublicKey: keyFile,
+	}, newTestLogger(), nil)
+
+	var session *UserSession
+	router := gin.New()
+	router.GET("/api", oc.Secure(func(c *Context) {
+		session = c.Session
+		_ = c.ReturnObject("ok")
+	}, roles.Admin_User))
+
+	cases := []struct {
+		token    string
+		status   int
+		username string
+	}{
+		{ mintToken(t, key, userClaims("alice", role.User)),           http.StatusOK,           "alice" },
+		{ mintToken(t, newTestKey(t), userClaims("alice", role.User)), http.StatusUnauthorized, ""      },
+		{ unsignedToken(userClaims("alice", role.User)),               http.StatusUnauthorized, ""      },
+		{ "",                                                          http.StatusOK,           "dev"   },
+	}
+
+	for i, tc := range cases {
+		session = nil
+
+		rr := serve(router, "GET", "/api", tc.token, nil)
+		if rr.Code != tc.status {
+			t.Errorf("Case %d: expected status %d but got %d", i, tc.status, rr.Code)
+			continue
+		}
+
+		if tc.username != "" && session.Username != tc.username {
+			t.Errorf("Case %d: expected username %s but got %s", i, tc.username, session.Username)
+		}
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,217 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/bit-fever/core/msg"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+)
+
+//=============================================================================
+
+const DefaultEnrichmentTTL = 10 * time.Minute
+
+//=============================================================================
+
+type Attributes map[string]any
+
+//-----------------------------------------------------------------------------
+
+type AttributeKey[T any] struct {
+	name string
+}
+
+//-----------------------------------------------------------------------------
+
+var LocationAttribute = NewAttributeKey[*time.Location]("location")
+
+//=============================================================================
+//--- Loads the attributes of the user the request acts for (UserSession.OnBehalfOf)
+
+type SessionEnricher func(ctx context.Context, us *UserSession) (Attributes, error)
+
+//=============================================================================
+
+type SessionEnrichment struct {
+	sync.RWMutex
+	enricher  SessionEnricher
+	ttl       time.Duration
+	cache     *expiringCache[Attributes]
+	propagate bool
+}
+
+//=============================================================================
+
+type sessionChange struct {
+	Realm    string
+	Username string
+}
+
+//=============================================================================
+//===
+//=== Attributes
+//===
+//=============================================================================
+
+func NewAttributeKey[T any](name string) AttributeKey[T] {
+	return AttributeKey[T]{ name: name }
+}
+
+//=============================================================================
+
+func SetAttribute[T any](attrs Attributes, key AttributeKey[T], value T) {
+	attrs[key.name] = value
+}
+
+//=============================================================================
+
+func GetAttribute[T any](us *UserSession, key AttributeKey[T]) (T, bool) {
+	value, ok := us.Attributes[key.name].(T)
+	return value, ok
+}
+
+//=============================================================================
+//===
+//=== Enrichment
+//===
+//=============================================================================
+
+func NewSessionEnrichment(enricher SessionEnricher, ttl time.Duration) *SessionEnrichment {
+	if ttl <= 0 {
+		ttl = DefaultEnrichmentTTL
+	}
+
+	return &SessionEnrichment{
+		enricher: enricher,
+		ttl     : ttl,
+		cache   : newExpiringCache[Attributes](DefaultCacheSize),
+	}
+}
+
+//=============================================================================
+//--- Each session gets its own copy of the attributes, so that handlers cannot
+//--- change the cached ones
+
+func (se *SessionEnrichment) Enrich(ctx context.Context, us *UserSession) error {
+	key := enrichmentKey(us.Realm, us.OnBehalfOf)
+
+	if attrs, ok := se.cache.Get(key); ok {
+		us.Attributes = maps.Clone(attrs)
+		return nil
+	}
+
+	attrs, err := se.enricher(ctx, us)
+	if err != nil {
+		return err
+	}
+
+	if attrs == nil {
+		attrs = Attributes{}
+	}
+
+	se.cache.Put(key, maps.Clone(attrs), time.Now().Add(se.ttl))
+	us.Attributes = attrs
+
+	return nil
+}
+
+//=============================================================================
+//--- The realm is empty for the primary issuer. An empty username drops the
+//--- attributes of all users
+
+func (se *SessionEnrichment) Invalidate(realm string, username string) error {
+	change := &sessionChange{ Realm: realm, Username: username }
+	se.apply(change)
+
+	se.RLock()
+	propagate := se.propagate
+	se.RUnlock()
+
+	if !propagate {
+		return nil
+	}
+
+	return msg.SendMessage(msg.ExAuth, msg.SourceSessionChange, msg.TypeChange, change)
+}
+
+//=============================================================================
+
+func (se *SessionEnrichment) EnablePropagation() {
+	se.Lock()
+	se.propagate = true
+	se.Unlock()
+
+	listenAuthBroadcast(msg.SourceSessionChange, se.onMessage)
+}
+
+//=============================================================================
+
+func (se *SessionEnrichment) CacheStats() CacheStats {
+	return se.cache.Stats()
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (se *SessionEnrichment) apply(change *sessionChange) {
+	if change.Username == "" {
+		se.cache.Clear()
+	} else {
+		se.cache.Remove(enrichmentKey(change.Realm, change.Username))
+	}
+}
+
+//=============================================================================
+
+func (se *SessionEnrichment) onMessage(m *msg.Message) bool {
+	if m.Source != msg.SourceSessionChange {
+		return true
+	}
+
+	var change sessionChange
+	if err := json.Unmarshal(m.Entity, &change); err != nil {
+		slog.Error("Cannot unmarshal session change message. Skipping", "error", err.Error())
+		return true
+	}
+
+	se.apply(&change)
+	return true
+}
+
+//=============================================================================
+
+func enrichmentKey(realm string, username string) string {
+	return realm +"|"+ username
+}
+
+//=============================================================================
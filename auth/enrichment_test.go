@@ -0,0 +1,202 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/bit-fever/core/datatype"
+	"github.com/bit-fever/core/msg"
+	"github.com/bit-fever/core/req"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+var maxOrderAttribute = NewAttributeKey[int]("maxOrder")
+
+//=============================================================================
+
+func TestSecureSessionEnrichment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key := newTestKey(t)
+	oc  := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+
+	tokyo := time.FixedZone("JST", 9*3600)
+	calls := map[string]int{}
+
+	se := NewSessionEnrichment(func(ctx context.Context, us *UserSession) (Attributes, error) {
+		calls[us.OnBehalfOf]++
+		if us.OnBehalfOf == "broken" {
+			return nil, errors.New("profile service down")
+		}
+
+		attrs := Attributes{}
+		SetAttribute(attrs, LocationAttribute, tokyo)
+		SetAttribute(attrs, maxOrderAttribute, 100)
+		return attrs, nil
+	}, time.Minute)
+	oc.SetSessionEnrichment(se)
+
+	var location *time.Location
+	var maxOrder int
+	var today    datatype.IntDate
+
+	router := gin.New()
+	router.GET("/api", oc.Secure(func(c *Context) {
+		location    = c.Session.Location()
+		maxOrder, _ = GetAttribute(c.Session, maxOrderAttribute)
+		today       = datatype.Today(location)
+		_ = c.ReturnObject("ok")
+	}, roles.Admin_User_Service))
+
+	alice   := mintToken(t, key, userClaims("alice",     role.User))
+	service := mintToken(t, key, userClaims("inventory", role.Service))
+
+	for i := 0; i < 3; i++ {
+		if rr := serve(router, "GET", "/api", alice, nil); rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d but got %d", http.StatusOK, rr.Code)
+		}
+	}
+
+	if calls["alice"] != 1 || location != tokyo || maxOrder != 100 || today != datatype.Today(tokyo) {
+		t.Errorf("Unexpected enrichment: calls=%d location=%v maxOrder=%d", calls["alice"], location, maxOrder)
+	}
+
+	//--- Delegated calls are enriched for the represented user
+
+	serve(router, "GET", "/api", service, map[string]string{ req.OnBehalfOf: "alice" })
+	if calls["alice"] != 1 || calls["inventory"] != 0 {
+		t.Errorf("Unexpected enrichment calls: %v", calls)
+	}
+
+	if err := se.Invalidate("", "alice"); err != nil {
+		t.Fatalf("Invalidate failed: %v", err)
+	}
+
+	serve(router, "GET", "/api", alice, nil)
+	if calls["alice"] != 2 {
+		t.Errorf("Expected the attributes to be reloaded, calls=%d", calls["alice"])
+	}
+
+	if rr := serve(router, "GET", "/api", service, map[string]string{ req.OnBehalfOf: "broken" }); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d but got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+}
+
+//=============================================================================
+
+func TestSessionEnrichmentMessages(t *testing.T) {
+	calls := 0
+	se    := NewSessionEnrichment(func(ctx context.Context, us *UserSession) (Attributes, error) {
+		calls++
+		return nil, nil
+	}, time.Minute)
+
+	enrich := func(username string) {
+		if err := se.Enrich(context.Background(), &UserSession{ Username: username, OnBehalfOf: username }); err != nil {
+			t.Fatalf("Enrich failed: %v", err)
+		}
+	}
+
+	enrich("alice")
+	enrich("bob")
+
+	entity, _ := json.Marshal(&sessionChange{ Username: "alice" })
+	se.onMessage(&msg.Message{ Source: msg.SourceSessionChange, Type: msg.TypeChange, Entity: entity })
+
+	enrich("alice")
+	enrich("bob")
+	if calls != 3 {
+		t.Errorf("Expected 3 enrichments but got %d", calls)
+	}
+
+	entity, _ = json.Marshal(&sessionChange{})
+	se.onMessage(&msg.Message{ Source: msg.SourceSessionChange, Type: msg.TypeChange, Entity: entity })
+
+	enrich("alice")
+	enrich("bob")
+	if calls != 5 {
+		t.Errorf("Expected 5 enrichments but got %d", calls)
+	}
+
+	if loc := (&UserSession{}).Location(); loc != time.UTC {
+		t.Errorf("Expected UTC as the default location but got %v", loc)
+	}
+}
+
+//=============================================================================
+
+func TestSessionEnrichmentIsolation(t *testing.T) {
+	calls := map[string]int{}
+	se    := NewSessionEnrichment(func(ctx context.Context, us *UserSession) (Attributes, error) {
+		calls[us.Realm]++
+		attrs := Attributes{}
+		SetAttribute(attrs, maxOrderAttribute, 100)
+		return attrs, nil
+	}, time.Minute)
+
+	enrich := func(realm string) *UserSession {
+		us := &UserSession{ Realm: realm, Username: "alice", OnBehalfOf: "alice" }
+		if err := se.Enrich(context.Background(), us); err != nil {
+			t.Fatalf("Enrich failed: %v", err)
+		}
+		return us
+	}
+
+	//--- Same username, different realms
+
+	SetAttribute(enrich("").Attributes, maxOrderAttribute, 1)
+	SetAttribute(enrich("partner").Attributes, maxOrderAttribute, 1)
+
+	if calls[""] != 1 || calls["partner"] != 1 {
+		t.Errorf("Expected one enrichment per realm but got %v", calls)
+	}
+
+	if maxOrder, _ := GetAttribute(enrich(""), maxOrderAttribute); maxOrder != 100 {
+		t.Errorf("The cached attributes were changed: %d", maxOrder)
+	}
+
+	entity, _ := json.Marshal(&sessionChange{ Realm: "partner", Username: "alice" })
+	dispatch := authListeners.handlers[msg.SourceSessionChange]
+	authListeners.handlers[msg.SourceSessionChange] = []func(m *msg.Message) bool{ se.onMessage }
+	dispatchAuthBroadcast(&msg.Message{ Source: msg.SourceSessionChange, Type: msg.TypeChange, Entity: entity })
+	authListeners.handlers[msg.SourceSessionChange] = dispatch
+
+	enrich("")
+	enrich("partner")
+	if calls[""] != 1 || calls["partner"] != 2 {
+		t.Errorf("Expected only the partner realm to be reloaded but got %v", calls)
+	}
+}
+
+//=============================================================================
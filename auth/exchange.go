@@ -0,0 +1,274 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"errors"
+	"github.com/bit-fever/core/req"
+	"net/url"
+	"slices"
+	"time"
+)
+
+//=============================================================================
+
+const (
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	TokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+//=============================================================================
+
+var exchanges = newExpiringCache[string](DefaultCacheSize)
+
+//=============================================================================
+//===
+//=== Public functions
+//===
+//=============================================================================
+//--- Exchanges a user token for a token scoped to the given audience
+
+func ExchangeToken(subjectToken string, audience string) (string, error) {
+	return ExchangeTokenFor(DefaultProfile, subjectToken, audience)
+}
+
+//=============================================================================
+
+func ExchangeTokenFor(profile string, subjectToken string, audience string) (string, error) {
+	rc, err := getProfile(profile)
+	if err != nil {
+		return "", err
+	}
+
+	return rc.Exchange(subjectToken, audience)
+}
+
+//=============================================================================
+//--- Uses the service token to obtain a token for the given subject (user). The
+//--- audience must be allowed by the profile's impersonation settings
+
+func ImpersonateSubject(subject string, audience string) (string, error) {
+	rc, err := getProfile(DefaultProfile)
+	if err != nil {
+		return "", err
+	}
+
+	return rc.Impersonate(subject, audience)
+}
+
+//=============================================================================
+
+func ExchangeCacheStats() CacheStats {
+	return exchanges.Stats()
+}
+
+//=============================================================================
+
+func (rc *RestContext) Exchange(subjectToken string, audience string) (string, error) {
+	if subjectToken == "" {
+		return "", errors.New("token exchange without a subject token")
+	}
+
+	key := rc.name +"|token|"+ hashToken(subjectToken) +"|"+ audience
+
+	return rc.exchange(key, subjectToken, "", audience, tokenExpiry(subjectToken))
+}
+
+//=============================================================================
+
+func (rc *RestContext) Impersonate(subject string, audience string) (string, error) {
+	return rc.impersonate("", subject, audience, time.Time{})
+}
+
+//=============================================================================
+//===
+//=== Context methods
+//===
+//=============================================================================
+//--- Returns a token for the target service on behalf of the current user.
+//--- Delegated sessions are exchanged with the service token for the subject,
+//--- but only for callers listed, by verified subject, in the profile's
+//--- impersonation settings. Otherwise the caller's own token is exchanged
+
+func (c *Context) DelegatedToken(audience string) (string, error) {
+	if c.revocation != nil && c.revocation.IsRevoked(c.Session) {
+		return "", req.NewUnauthorizedError("Authorisation failed: the session has been revoked")
+	}
+
+	rc, err := getProfile(DefaultProfile)
+	if err != nil {
+		return "", err
+	}
+
+	us := c.Session
+
+	if us.IsDelegated() {
+		if !rc.canImpersonate(us.Issuer, us.Subject) {
+			return "", req.NewForbiddenError("Caller not allowed to obtain tokens on behalf of another user: %v", us.Username)
+		}
+
+		return rc.impersonate(us.Issuer, us.OnBehalfOf, audience, us.Expiry)
+	}
+
+	if c.Token == "" {
+		return "", errors.New("token exchange without a subject token")
+	}
+
+	key := rc.name +"|token|"+ hashToken(c.Token) +"|"+ audience
+
+	return rc.exchange(key, c.Token, "", audience, us.Expiry)
+}
+
+//=============================================================================
+//--- Calls the target service with a delegated token, without OnBehalfOf
+
+func (c *Context) AsDelegated(audience string) *Caller {
+	return &Caller{
+		ctx  : c.Ctx,
+		token: func() (string, error) { return c.DelegatedToken(audience) },
+	}
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func getProfile(name string) (*RestContext, error) {
+	profiles.RLock()
+	rc, ok := profiles.contexts[name]
+	profiles.RUnlock()
+
+	if !ok {
+		return nil, errors.New("unknown token profile: "+ name)
+	}
+
+	return rc, nil
+}
+
+//=============================================================================
+//--- Subjects are namespaced by the issuer of the caller's session, when known
+
+func (rc *RestContext) impersonate(issuer string, subject string, audience string, notAfter time.Time) (string, error) {
+	if subject == "" {
+		return "", errors.New("token exchange without a subject")
+	}
+
+	if rc.impersonation == nil || !slices.Contains(rc.impersonation.Audiences, audience) {
+		return "", errors.New("token profile '"+ rc.name +"': impersonation is not allowed for the audience: "+ audience)
+	}
+
+	token, err := rc.Token()
+	if err != nil {
+		return "", err
+	}
+
+	rc.RLock()
+	validUntil := rc.validUntil
+	rc.RUnlock()
+
+	if notAfter.IsZero() || validUntil.Before(notAfter) {
+		notAfter = validUntil
+	}
+
+	key := rc.name +"|subject|"+ subjectKey(issuer, subject) +"|"+ audience
+
+	return rc.exchange(key, token, subject, audience, notAfter)
+}
+
+//=============================================================================
+
+func (rc *RestContext) canImpersonate(issuer string, subject string) bool {
+	if rc.impersonation == nil || subject == "" {
+		return false
+	}
+
+	for _, caller := range rc.impersonation.Callers {
+		if caller.Issuer == issuer && caller.Subject == subject {
+			return true
+		}
+	}
+
+	return false
+}
+
+//=============================================================================
+//--- Cached tokens never outlive notAfter (the subject token's expiry), if set
+
+func (rc *RestContext) exchange(key string, subjectToken string, subject string, audience string, notAfter time.Time) (string, error) {
+	if token, ok := exchanges.Get(key); ok {
+		return token, nil
+	}
+
+	params := url.Values{}
+	params.Set("grant_type",           GrantTypeTokenExchange)
+	params.Set("subject_token",        subjectToken)
+	params.Set("subject_token_type",   TokenTypeAccessToken)
+	params.Set("requested_token_type", TokenTypeAccessToken)
+
+	if audience != "" {
+		params.Set("audience", audience)
+	}
+
+	if subject != "" {
+		params.Set("requested_subject", subject)
+	}
+
+	t, err := rc.postTokenRequest(params)
+	if err != nil {
+		return "", err
+	}
+
+	lifetime := time.Duration(t.ExpiresIn) * time.Second
+	skew     := min(maxExpirySkew, lifetime / 10)
+	expiry   := time.Now().Add(lifetime - skew)
+
+	if !notAfter.IsZero() && notAfter.Before(expiry) {
+		expiry = notAfter
+	}
+
+	exchanges.Put(key, t.AccessToken, expiry)
+
+	return t.AccessToken, nil
+}
+
+//=============================================================================
+//--- Reads the expiry of a JWT without verifying it: the token has already been
+//--- verified, or will be by the authority. Opaque tokens have no expiry
+
+func tokenExpiry(token string) time.Time {
+	var claims struct {
+		Expiry int64 `json:"exp"`
+	}
+
+	if _, err := decodeJwtPayload(token, &claims); err != nil || claims.Expiry == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(claims.Expiry, 0)
+}
+
+//=============================================================================
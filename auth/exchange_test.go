@@ -0,0 +1,236 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/bit-fever/core/req"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+func TestTokenExchange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Cleanup(exchanges.Clear)
+
+	ts := newTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.PostForm.Get("grant_type") != GrantTypeTokenExchange {
+			writeToken(w, "service-token", 300)
+			return
+		}
+
+		subject := r.PostForm.Get("requested_subject")
+		if subject == "" {
+			subject = r.PostForm.Get("subject_token")
+		}
+
+		writeTokenResponse(w, &TokenResponse{
+			AccessToken    : subject +"@"+ r.PostForm.Get("audience"),
+			ExpiresIn      : 300,
+			IssuedTokenType: TokenTypeAccessToken,
+		})
+	})
+
+	rc, err := NewRestContext(&core.TokenProfile{
+		Name         : DefaultProfile,
+		Authority    : ts.URL,
+		ClientId     : "bf-service",
+		ClientSecret : "a&b",
+		Impersonation: &core.Impersonation{
+			Audiences: []string{ "bf-inventory" },
+			Callers  : []core.ImpersonationCaller{{ Issuer: testIssuer, Subject: "inventory-id" }},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRestContext failed: %v", err)
+	}
+	setTokenProfile(t, rc)
+
+	for i := 0; i < 2; i++ {
+		if token, err := ExchangeToken("user-token", "bf-inventory"); err != nil || token != "user-token@bf-inventory" {
+			t.Errorf("Unexpected exchanged token: %s (%v)", token, err)
+		}
+	}
+
+	if token, err := ExchangeToken("user-token", "bf-portfolio"); err != nil || token != "user-token@bf-portfolio" {
+		t.Errorf("Unexpected exchanged token: %s (%v)", token, err)
+	}
+
+	if calls := ts.calls.Load(); calls != 2 {
+		t.Errorf("Expected one exchange per audience, but got %d calls", calls)
+	}
+
+	form := ts.form(0)
+	if form["subject_token_type"] != TokenTypeAccessToken || form["client_id"] != "bf-service" {
+		t.Errorf("Unexpected exchange request: %v", form)
+	}
+
+	//--- A delegated session exchanges the service token for the subject
+
+	key     := newTestKey(t)
+	oc      := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	backend := newEchoServer(t, 0)
+
+	var delegated, direct echoedHeaders
+	router := gin.New()
+	router.GET("/api", oc.Secure(func(c *Context) {
+		if err := c.AsDelegated("bf-inventory").Get(backend.Client(), backend.URL, &delegated); err != nil {
+			c.ReturnError(err)
+			return
+		}
+		_ = c.ReturnObject("ok")
+	}, roles.Admin_User_Service))
+
+	router.GET("/direct", oc.Secure(func(c *Context) {
+		token, err := c.DelegatedToken("bf-inventory")
+		if err != nil {
+			c.ReturnError(err)
+			return
+		}
+		direct.Authorization = token
+		_ = c.ReturnObject("ok")
+	}, roles.Admin_User_Service))
+
+	claims := userClaims("inventory", role.Service)
+	claims["sub"] = "inventory-id"
+
+	serviceToken := mintToken(t, key, claims)
+	if rr := serve(router, "GET", "/api", serviceToken, map[string]string{ req.OnBehalfOf: "alice" }); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	if delegated.Authorization != "Bearer alice@bf-inventory" || delegated.OnBehalfOf != "" {
+		t.Errorf("Unexpected delegated headers: %+v", delegated)
+	}
+
+	if form := ts.form(-1); form["requested_subject"] != "alice" || form["subject_token"] != "service-token" {
+		t.Errorf("Unexpected impersonation request: %v", form)
+	}
+
+	userToken := mintToken(t, key, userClaims("bob", role.User))
+	if rr := serve(router, "GET", "/direct", userToken, nil); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	if direct.Authorization != userToken +"@bf-inventory" {
+		t.Errorf("Unexpected direct token: %s", direct.Authorization)
+	}
+}
+
+//=============================================================================
+
+func TestDelegatedTokenRestrictions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Cleanup(exchanges.Clear)
+
+	ts := newTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeTokenResponse(w, &TokenResponse{ AccessToken: "exchanged", ExpiresIn: 3600 })
+	})
+
+	rc, err := NewRestContext(&core.TokenProfile{
+		Name         : DefaultProfile,
+		Authority    : ts.URL,
+		ClientId     : "bf-service",
+		Impersonation: &core.Impersonation{
+			Audiences: []string{ "bf-inventory" },
+			Callers  : []core.ImpersonationCaller{{ Issuer: testIssuer, Subject: "inventory-id" }},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRestContext failed: %v", err)
+	}
+	setTokenProfile(t, rc)
+
+	key := newTestKey(t)
+	oc  := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+
+	router := gin.New()
+	router.GET("/token/:audience", oc.Secure(func(c *Context) {
+		if c.Gin.Query("revoke") != "" {
+			_ = oc.Revocations().RevokeSession(c.Session.SessionID)
+		}
+
+		if _, err := c.DelegatedToken(c.Gin.Param("audience")); err != nil {
+			c.ReturnError(err)
+			return
+		}
+		_ = c.ReturnObject("ok")
+	}, roles.Admin_User_Service))
+
+	service := func(subject string) string {
+		claims := userClaims("inventory", role.Service)
+		claims["sub"] = subject
+		return mintToken(t, key, claims)
+	}
+
+	expiry := time.Now().Add(time.Minute).Truncate(time.Second)
+	claims := userClaims("bob", role.User)
+	claims["exp"] = expiry.Unix()
+	claims["sid"] = "session-1"
+	user := mintToken(t, key, claims)
+
+	alice := map[string]string{ req.OnBehalfOf: "alice" }
+
+	cases := []struct {
+		path    string
+		token   string
+		headers map[string]string
+		status  int
+	}{
+		{ "/token/bf-inventory",          service("inventory-id"), alice, http.StatusOK                  },
+		{ "/token/bf-portfolio",          service("inventory-id"), alice, http.StatusInternalServerError },
+		{ "/token/bf-inventory",          service("other-id"),     alice, http.StatusForbidden           },
+		{ "/token/bf-portfolio",          user,                    nil,   http.StatusOK                  },
+		{ "/token/bf-portfolio?revoke=1", user,                    nil,   http.StatusUnauthorized        },
+	}
+
+	for i, tc := range cases {
+		if rr := serve(router, "GET", tc.path, tc.token, tc.headers); rr.Code != tc.status {
+			t.Errorf("Case %d: expected status %d but got %d (%s)", i, tc.status, rr.Code, rr.Body.String())
+		}
+	}
+
+	//--- Exchanged tokens are not cached beyond the subject token's expiry
+
+	exchanges.Lock()
+	defer exchanges.Unlock()
+
+	entry, ok := exchanges.entries[rc.name +"|token|"+ hashToken(user) +"|bf-portfolio"]
+	if !ok || !entry.expiry.Equal(expiry) {
+		t.Errorf("Expected the cache entry to expire at %v", expiry)
+	}
+
+	if _, err := ImpersonateSubject("alice", "bf-portfolio"); err == nil {
+		t.Errorf("Expected impersonation to be refused for an audience not configured")
+	}
+}
+
+//=============================================================================
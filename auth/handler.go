@@ -0,0 +1,63 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"github.com/bit-fever/core/req"
+	"net/http"
+)
+
+//=============================================================================
+
+type TypedService[Req any, Resp any] func(c *Context, in *Req) (*Resp, error)
+
+//=============================================================================
+//--- Adapts a typed handler to a RestService: the input is bound from the body,
+//--- the query string and the URL, then validated. A nil response means 204
+
+func Typed[Req any, Resp any](h TypedService[Req, Resp]) RestService {
+	return func(c *Context) {
+		in := new(Req)
+		if err := req.BindParams(c.Gin, in); err != nil {
+			c.ReturnError(err)
+			return
+		}
+
+		out, err := h(c, in)
+		if err != nil {
+			c.ReturnError(err)
+			return
+		}
+
+		if out == nil {
+			c.Gin.Status(http.StatusNoContent)
+			return
+		}
+
+		_ = c.ReturnObject(out)
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,173 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"encoding/json"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/bit-fever/core/req"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//=============================================================================
+
+type updateOrderRequest struct {
+	Id       uint   `uri:"id"         binding:"required"`
+	DryRun   bool   `form:"dryRun"`
+	Quantity int    `json:"quantity"  binding:"required,gt=0"`
+	Note     string `json:"note"`
+}
+
+//-----------------------------------------------------------------------------
+
+type updateOrderResponse struct {
+	Id       uint   `json:"id"`
+	DryRun   bool   `json:"dryRun"`
+	Quantity int    `json:"quantity"`
+	Username string `json:"username"`
+}
+
+//=============================================================================
+
+func TestTypedService(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key := newTestKey(t)
+	oc  := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+
+	router := gin.New()
+	router.PUT("/orders/:id", oc.Secure(Typed(func(c *Context, in *updateOrderRequest) (*updateOrderResponse, error) {
+		switch {
+		case in.Id == 404:
+			return nil, req.NewNotFoundError("Order not found: %v", in.Id)
+		case in.Note == "skip":
+			return nil, nil
+		}
+
+		return &updateOrderResponse{
+			Id      : in.Id,
+			DryRun  : in.DryRun,
+			Quantity: in.Quantity,
+			Username: c.Session.Username,
+		}, nil
+	}), roles.User))
+
+	token := mintToken(t, key, userClaims("alice", role.User))
+
+	cases := []struct {
+		path   string
+		body   string
+		status int
+	}{
+		{ "/orders/7?dryRun=true", `{"id": 99, "quantity": 10}`,       http.StatusOK         },
+		{ "/orders/7",             `{"quantity": 0}`,                  http.StatusBadRequest },
+		{ "/orders/7",             `{"quantity": "ten"}`,              http.StatusBadRequest },
+		{ "/orders/x",             `{"quantity": 10}`,                 http.StatusBadRequest },
+		{ "/orders/7",             ``,                                 http.StatusBadRequest },
+		{ "/orders/404",           `{"quantity": 10}`,                 http.StatusNotFound   },
+		{ "/orders/7",             `{"quantity": 10, "note": "skip"}`, http.StatusNoContent  },
+	}
+
+	for i, tc := range cases {
+		rq := httptest.NewRequest("PUT", tc.path, strings.NewReader(tc.body))
+		rq.Header.Set("Authorization", "Bearer "+ token)
+		rq.Header.Set("Content-Type", req.ApplicationJson)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, rq)
+
+		if rr.Code != tc.status {
+			t.Errorf("Case %d: expected status %d but got %d (%s)", i, tc.status, rr.Code, rr.Body.String())
+			continue
+		}
+
+		if i == 0 {
+			var resp updateOrderResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Invalid response: %v", err)
+			}
+
+			if resp.Id != 7 || !resp.DryRun || resp.Quantity != 10 || resp.Username != "alice" {
+				t.Errorf("Unexpected response: %+v", resp)
+			}
+		}
+	}
+}
+
+//=============================================================================
+
+type bindOrderRequest struct {
+	Id       uint   `json:"id" uri:"id"`
+	Quantity int    `json:"quantity"`
+	Owner    string `json:"owner"`
+	Limit    int    `json:"limit" form:"limit,default=50"`
+}
+
+//=============================================================================
+
+func TestBindParamsKeepsBodyValues(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var in bindOrderRequest
+
+	router := gin.New()
+	router.PUT("/orders/:id", func(c *gin.Context) {
+		in = bindOrderRequest{}
+		if err := req.BindParams(c, &in); err != nil {
+			c.Status(http.StatusBadRequest)
+		}
+	})
+
+	cases := []struct {
+		path     string
+		body     string
+		expected bindOrderRequest
+	}{
+		{ "/orders/7?Quantity=999&Owner=eve&Id=3", `{"quantity": 10, "owner": "alice", "limit": 5}`, bindOrderRequest{ 7, 10, "alice", 5 }},
+		{ "/orders/7?limit=999",                   `{"quantity": 10, "limit": 5}`,                    bindOrderRequest{ 7, 10, "",      5 }},
+		{ "/orders/7",                             `{"quantity": 10}`,                                bindOrderRequest{ 7, 10, "",     50 }},
+		{ "/orders/7?limit=20",                    `{"quantity": 10}`,                                bindOrderRequest{ 7, 10, "",     20 }},
+		{ "/orders/7",                             `{"id": 3, "quantity": 10}`,                       bindOrderRequest{ 7, 10, "",     50 }},
+	}
+
+	for i, tc := range cases {
+		rq := httptest.NewRequest("PUT", tc.path, strings.NewReader(tc.body))
+		rq.Header.Set("Content-Type", req.ApplicationJson)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, rq)
+
+		if rr.Code != http.StatusOK || in != tc.expected {
+			t.Errorf("Case %d: expected %+v but got %+v (status %d)", i, tc.expected, in, rr.Code)
+		}
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,219 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/req"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//=============================================================================
+
+type introspectionVerifier struct {
+	endpoint     string
+	clientId     string
+	clientSecret string
+	client       *http.Client
+	cache        *expiringCache[*VerifiedToken]
+}
+
+//=============================================================================
+
+type introspectionResponse struct {
+	Active   bool     `json:"active"`
+	Issuer   string   `json:"iss,omitempty"`
+	Subject  string   `json:"sub,omitempty"`
+	Audience audience `json:"aud,omitempty"`
+	IssuedAt int64    `json:"iat,omitempty"`
+	Expiry   int64    `json:"exp,omitempty"`
+}
+
+//=============================================================================
+
+type audience []string
+
+//-----------------------------------------------------------------------------
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{ single }
+		return nil
+	}
+
+	var multi []string
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return err
+	}
+
+	*a = multi
+	return nil
+}
+
+//=============================================================================
+//===
+//=== Public functions
+//===
+//=============================================================================
+
+func NewIntrospectionVerifier(auth *core.Authentication, client *http.Client) (TokenVerifier, error) {
+	ccontext      := oidc.ClientContext(context.Background(), client)
+	provider, err := oidc.NewProvider(ccontext, auth.Authority)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints struct {
+		Introspection string `json:"introspection_endpoint"`
+	}
+
+	if err = provider.Claims(&endpoints); err != nil {
+		return nil, err
+	}
+
+	if endpoints.Introspection == "" {
+		return nil, errors.New("the provider does not expose an introspection endpoint: "+ auth.Authority)
+	}
+
+	return NewIntrospectionVerifierWithEndpoint(endpoints.Introspection, auth, client), nil
+}
+
+//=============================================================================
+
+func NewIntrospectionVerifierWithEndpoint(endpoint string, auth *core.Authentication, client *http.Client) TokenVerifier {
+	return &introspectionVerifier{
+		endpoint    : endpoint,
+		clientId    : auth.ClientId,
+		clientSecret: auth.ClientSecret,
+		client      : client,
+		cache       : newExpiringCache[*VerifiedToken](DefaultCacheSize),
+	}
+}
+
+//=============================================================================
+
+func (v *introspectionVerifier) Verify(ctx context.Context, rawToken string) (*VerifiedToken, error) {
+	key := hashToken(rawToken)
+
+	if vt, ok := v.cache.Get(key); ok {
+		return vt, nil
+	}
+
+	vt, err := v.introspect(ctx, rawToken)
+	if err != nil {
+		return nil, err
+	}
+
+	v.cache.Put(key, vt, vt.Expiry)
+
+	return vt, nil
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (v *introspectionVerifier) introspect(ctx context.Context, rawToken string) (*VerifiedToken, error) {
+	form := url.Values{}
+	form.Set("token",           rawToken)
+	form.Set("token_type_hint", "access_token")
+
+	rq, err := http.NewRequestWithContext(ctx, "POST", v.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	rq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rq.Header.Set("Accept",       req.ApplicationJson)
+	rq.SetBasicAuth(url.QueryEscape(v.clientId), url.QueryEscape(v.clientSecret))
+
+	var claims map[string]any
+	res, err := v.client.Do(rq)
+	if err = req.BuildResponse(res, err, &claims); err != nil {
+		return nil, err
+	}
+
+	return buildIntrospectedToken(claims)
+}
+
+//=============================================================================
+
+func buildIntrospectedToken(claims map[string]any) (*VerifiedToken, error) {
+	//--- RFC 7662 uses 'username' while tokens use 'preferred_username'
+
+	if _, ok := claims["preferred_username"]; !ok {
+		if username, ok := claims["username"]; ok {
+			claims["preferred_username"] = username
+		}
+	}
+
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	var ir introspectionResponse
+	if err = json.Unmarshal(data, &ir); err != nil {
+		return nil, err
+	}
+
+	if !ir.Active {
+		return nil, errors.New("token is not active")
+	}
+
+	//--- Without 'exp' the result is not cached (see expiringCache.Put)
+
+	vt := &VerifiedToken{
+		Issuer  : ir.Issuer,
+		Subject : ir.Subject,
+		Audience: ir.Audience,
+		Claims  : data,
+	}
+
+	if ir.IssuedAt != 0 {
+		vt.IssuedAt = time.Unix(ir.IssuedAt, 0)
+	}
+
+	if ir.Expiry != 0 {
+		vt.Expiry = time.Unix(ir.Expiry, 0)
+		if !time.Now().Before(vt.Expiry) {
+			return nil, errors.New("token is expired")
+		}
+	}
+
+	return vt, nil
+}
+
+//=============================================================================
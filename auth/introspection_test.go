@@ -0,0 +1,163 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+var testCredentials = &core.Authentication{
+	ClientId    : "bf-service",
+	ClientSecret: "s3cr&t+value",
+}
+
+//=============================================================================
+
+func newIntrospectionServer(t *testing.T, calls *atomic.Int32) *httptest.Server {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer"                : srv.URL,
+			"introspection_endpoint": srv.URL +"/introspect",
+		})
+	})
+
+	mux.HandleFunc("/introspect", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != testCredentials.ClientId || secret != "s3cr%26t%2Bvalue" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		response := map[string]any{ "active": false }
+
+		switch r.PostFormValue("token") {
+		case "good-token":
+			response = map[string]any{
+				"active"      : true,
+				"iss"         : srv.URL,
+				"sub"         : "user-id",
+				"aud"         : "bf-portfolio",
+				"username"    : "carol",
+				"exp"         : time.Now().Add(time.Hour).Unix(),
+				"realm_access": map[string]any{ "roles": []string{ "user" }},
+			}
+
+		case "expired-token":
+			response = map[string]any{
+				"active": true,
+				"exp"   : time.Now().Add(-time.Minute).Unix(),
+			}
+		}
+
+		_ = json.NewEncoder(w).Encode(response)
+	})
+
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+//=============================================================================
+
+func TestIntrospectionVerifier(t *testing.T) {
+	var calls atomic.Int32
+	srv := newIntrospectionServer(t, &calls)
+
+	auth := *testCredentials
+	auth.Authority = srv.URL
+
+	v, err := NewIntrospectionVerifier(&auth, srv.Client())
+	if err != nil {
+		t.Fatalf("NewIntrospectionVerifier failed: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		vt, err := v.Verify(context.Background(), "good-token")
+		if err != nil {
+			t.Fatalf("Verify failed: %v", err)
+		}
+
+		if len(vt.Audience) != 1 || vt.Audience[0] != "bf-portfolio" {
+			t.Errorf("Unexpected audience: %v", vt.Audience)
+		}
+	}
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 call to the introspection endpoint but got %d", calls.Load())
+	}
+
+	for _, token := range []string{ "expired-token", "unknown-token" } {
+		if _, err = v.Verify(context.Background(), token); err == nil {
+			t.Errorf("Expected an error for '%v'", token)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestSecureWithIntrospection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var calls atomic.Int32
+	srv := newIntrospectionServer(t, &calls)
+	v   := NewIntrospectionVerifierWithEndpoint(srv.URL +"/introspect", testCredentials, srv.Client())
+	oc  := NewOidcControllerWithVerifier(v, newTestLogger(), nil)
+
+	var username string
+	router := gin.New()
+	router.GET("/api", oc.Secure(func(c *Context) {
+		username = c.Session.Username
+		_ = c.ReturnObject("ok")
+	}, roles.User))
+
+	if rr := serve(router, "GET", "/api", "good-token", nil); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	if username != "carol" {
+		t.Errorf("Expected username 'carol' but got '%v'", username)
+	}
+
+	if rr := serve(router, "GET", "/api", "unknown-token", nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d but got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+//=============================================================================
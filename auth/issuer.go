@@ -0,0 +1,172 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"errors"
+	"github.com/bit-fever/core"
+	"log/slog"
+	"net/http"
+)
+
+//=============================================================================
+
+type trustedIssuer struct {
+	name      string
+	verifier  TokenVerifier
+	mapper    *ClaimMapper
+	namespace string
+}
+
+//=============================================================================
+//===
+//=== Public functions
+//===
+//=============================================================================
+//--- The first issuer is the primary one, the others are added with AddIssuer
+
+func NewOidcControllerWithIssuers(issuers []core.TrustedIssuer, client *http.Client, logger *slog.Logger, config any) *OidcController {
+	if len(issuers) == 0 {
+		core.ExitWithMessage("At least one trusted issuer is required")
+	}
+
+	oc := NewOidcControllerWithVerifier(nil, logger, config)
+
+	for i := range issuers {
+		ti := &issuers[i]
+
+		verifier, err := NewDiscoveryVerifier(ti.Authority, client)
+		core.ExitIfError(err)
+
+		if i == 0 {
+			oc.AddPrimaryIssuer(ti.Name, ti.Authority, verifier, &ti.ClaimMapping)
+		} else {
+			oc.AddIssuer(ti.Name, ti.Authority, verifier, &ti.ClaimMapping)
+		}
+	}
+
+	return oc
+}
+
+//=============================================================================
+//--- Users of the primary issuer keep their plain username and get the default
+//--- role mapping when none is configured
+
+func (oc *OidcController) AddPrimaryIssuer(name string, issuer string, verifier TokenVerifier, cfg *core.ClaimMapping) {
+	oc.addIssuer(issuer, &trustedIssuer{
+		name    : name,
+		verifier: verifier,
+		mapper  : NewClaimMapper(cfg),
+	})
+}
+
+//=============================================================================
+//--- Users of other issuers are namespaced as '<name>:<username>' and get no
+//--- roles unless their role mapping is configured explicitly
+
+func (oc *OidcController) AddIssuer(name string, issuer string, verifier TokenVerifier, cfg *core.ClaimMapping) {
+	if name == "" {
+		core.ExitWithMessage("Trusted issuer without a name: "+ issuer)
+	}
+
+	mapper := &ClaimMapper{}
+	if cfg != nil {
+		mapper = &ClaimMapper{
+			rules   : cfg.Roles,
+			username: cfg.Username,
+			email   : cfg.Email,
+			name    : cfg.Name,
+			surname : cfg.Surname,
+		}
+	}
+
+	oc.addIssuer(issuer, &trustedIssuer{
+		name     : name,
+		verifier : verifier,
+		mapper   : mapper,
+		namespace: name,
+	})
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (oc *OidcController) selectIssuer(rawToken string) (*trustedIssuer, error) {
+	if len(oc.issuers) > 0 {
+		if ti, ok := oc.issuers[peekIssuer(rawToken)]; ok {
+			return ti, nil
+		}
+	}
+
+	if oc.verifier == nil {
+		return nil, errors.New("token issuer is not trusted")
+	}
+
+	return &trustedIssuer{
+		verifier: oc.verifier,
+		mapper  : oc.mapper,
+	}, nil
+}
+
+//=============================================================================
+
+func (oc *OidcController) addIssuer(issuer string, ti *trustedIssuer) {
+	if oc.issuers == nil {
+		oc.issuers = map[string]*trustedIssuer{}
+	}
+
+	oc.issuers[issuer] = ti
+}
+
+//=============================================================================
+
+func (ti *trustedIssuer) qualify(username string) string {
+	if ti.namespace == "" || username == "" {
+		return username
+	}
+
+	return ti.namespace +":"+ username
+}
+
+//=============================================================================
+//--- Reads the 'iss' claim without verifying the token. The result is only used
+//--- to pick a verifier, which then checks the signature and the issuer itself
+
+func peekIssuer(rawToken string) string {
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+
+	if _, err := decodeJwtPayload(rawToken, &claims); err != nil {
+		return ""
+	}
+
+	return claims.Issuer
+}
+
+//=============================================================================
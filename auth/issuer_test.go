@@ -0,0 +1,127 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing"
+)
+
+//=============================================================================
+
+const (
+	partnerIssuer = "https://partner.test/oauth2"
+	guestIssuer   = "https://guest.test/oauth2"
+)
+
+//=============================================================================
+
+func TestSecureMultipleIssuers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	realmKey   := newTestKey(t)
+	partnerKey := newTestKey(t)
+
+	oc := NewOidcControllerWithVerifier(nil, newTestLogger(), nil)
+	oc.AddPrimaryIssuer("bf", testIssuer, NewLocalKeyVerifier(testIssuer, &realmKey.PublicKey), nil)
+	oc.AddIssuer("guest", guestIssuer, NewLocalKeyVerifier(guestIssuer, &partnerKey.PublicKey), nil)
+	oc.AddIssuer("partner", partnerIssuer, NewLocalKeyVerifier(partnerIssuer, &partnerKey.PublicKey), &core.ClaimMapping{
+		Username: "upn",
+		Roles   : []core.RoleMapping{
+			{ Claim: "groups", Value: "traders", Role: string(role.User) },
+		},
+	})
+
+	var session *UserSession
+	router := gin.New()
+	router.GET("/api", oc.Secure(func(c *Context) {
+		session = c.Session
+		_ = c.ReturnObject("ok")
+	}, roles.User))
+
+	partnerClaims := map[string]any{
+		"iss"   : partnerIssuer,
+		"upn"   : "carol@partner.test",
+		"groups": []string{ "traders" },
+	}
+
+	cases := []struct {
+		token    string
+		status   int
+		username string
+		realm    string
+	}{
+		{ mintToken(t, realmKey,   userClaims("alice", role.User)), http.StatusOK, "alice",              "bf"      },
+		{ mintToken(t, partnerKey, partnerClaims),                  http.StatusOK, "partner:carol@partner.test", "partner" },
+
+		//--- Extra issuers get no roles without an explicit mapping
+
+		{ mintToken(t, partnerKey, map[string]any{ "iss": guestIssuer, "preferred_username": "alice", "realm_access": map[string]any{ "roles": []string{ "admin", "user" } } }), http.StatusForbidden, "", "" },
+
+		//--- Signed by the wrong issuer's key
+
+		{ mintToken(t, realmKey, partnerClaims), http.StatusUnauthorized, "", "" },
+
+		//--- Unknown issuer
+
+		{ mintToken(t, realmKey, map[string]any{ "iss": "https://other.test" }), http.StatusUnauthorized, "", "" },
+	}
+
+	for i, tc := range cases {
+		session = nil
+
+		rr := serve(router, "GET", "/api", tc.token, nil)
+		if rr.Code != tc.status {
+			t.Errorf("Case %d: expected status %d but got %d", i, tc.status, rr.Code)
+			continue
+		}
+
+		if tc.status == http.StatusOK && (session.Username != tc.username || session.Realm != tc.realm) {
+			t.Errorf("Case %d: unexpected session %s/%s", i, session.Realm, session.Username)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestPeekIssuer(t *testing.T) {
+	key := newTestKey(t)
+
+	if iss := peekIssuer(mintToken(t, key, nil)); iss != testIssuer {
+		t.Errorf("Expected issuer %s but got %s", testIssuer, iss)
+	}
+
+	for _, token := range []string{ "", "opaque-token", "a.b.c", "a.!!.c" } {
+		if iss := peekIssuer(token); iss != "" {
+			t.Errorf("Expected no issuer for %q but got %s", token, iss)
+		}
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,159 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"encoding/json"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/req"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+//=============================================================================
+
+const BackChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+//=============================================================================
+
+type logoutToken struct {
+	JTI    string                     `json:"jti,omitempty"`
+	SID    string                     `json:"sid,omitempty"`
+	Events map[string]json.RawMessage `json:"events,omitempty"`
+	Nonce  *string                    `json:"nonce,omitempty"`
+}
+
+//=============================================================================
+//--- Logout tokens are JWTs: an introspection endpoint cannot verify them
+
+func (oc *OidcController) BackChannelLogout() func(c *gin.Context) {
+	if !oc.canVerifyLogout() {
+		core.ExitWithMessage("Back-channel logout requires a JWT verifier: introspection cannot verify logout tokens")
+	}
+
+	//--- Logout tokens already processed, to reject replays
+	seen := newExpiringCache[bool](DefaultCacheSize)
+
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+
+		rawToken := c.PostForm("logout_token")
+		if rawToken == "" {
+			req.ReturnError(c, req.NewBadRequestError("Missing the 'logout_token' parameter"))
+			return
+		}
+
+		ti, err := oc.selectIssuer(rawToken)
+		if err != nil {
+			req.ReturnError(c, req.NewBadRequestError("Invalid logout token: %v", err.Error()))
+			return
+		}
+
+		if _, ok := ti.verifier.(*introspectionVerifier); ok {
+			req.ReturnError(c, req.NewBadRequestError("Invalid logout token: the issuer cannot verify logout tokens"))
+			return
+		}
+
+		vt, err := ti.verifier.Verify(c.Request.Context(), rawToken)
+		if err != nil {
+			req.ReturnError(c, req.NewBadRequestError("Invalid logout token: %v", err.Error()))
+			return
+		}
+
+		var lt logoutToken
+		if err = vt.ParseClaims(&lt); err != nil {
+			req.ReturnError(c, req.NewBadRequestError("Invalid logout token claims: %v", err.Error()))
+			return
+		}
+
+		if _, ok := lt.Events[BackChannelLogoutEvent]; !ok || lt.Nonce != nil {
+			req.ReturnError(c, req.NewBadRequestError("The token is not a back-channel logout token"))
+			return
+		}
+
+		if lt.JTI == "" {
+			req.ReturnError(c, req.NewBadRequestError("The logout token has no 'jti'"))
+			return
+		}
+
+		replayKey := vt.Issuer +"|"+ lt.JTI
+		if _, ok := seen.Get(replayKey); ok {
+			req.ReturnError(c, req.NewBadRequestError("The logout token has already been used"))
+			return
+		}
+
+		r := &Revocation{
+			SessionID: lt.SID,
+		}
+
+		if r.SessionID == "" {
+			r.Issuer  = vt.Issuer
+			r.Subject = vt.Subject
+		}
+
+		if r.SessionID == "" && r.Subject == "" {
+			req.ReturnError(c, req.NewBadRequestError("The logout token has neither a 'sid' nor a 'sub'"))
+			return
+		}
+
+		expiry := vt.Expiry
+		if expiry.IsZero() {
+			expiry = time.Now().Add(oc.revocation.ttl)
+		}
+		seen.Put(replayKey, true, expiry)
+
+		if err = oc.revocation.Revoke(r); err != nil {
+			slog.Error("Cannot propagate the revocation", "error", err.Error())
+		}
+
+		oc.logger.Info("Back-channel logout received",
+			slog.String("sessionId", r.SessionID),
+			slog.String("subject",   r.Subject))
+
+		c.Status(http.StatusOK)
+	}
+}
+
+//=============================================================================
+
+func (oc *OidcController) canVerifyLogout() bool {
+	if oc.verifier != nil {
+		if _, ok := oc.verifier.(*introspectionVerifier); !ok {
+			return true
+		}
+	}
+
+	for _, ti := range oc.issuers {
+		if _, ok := ti.verifier.(*introspectionVerifier); !ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+//=============================================================================
@@ -0,0 +1,479 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"github.com/bit-fever/core/req"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+//=============================================================================
+
+type Schema map[string]any
+
+//=============================================================================
+
+type OpenApiDocument struct {
+	OpenApi    string                           `json:"openapi"`
+	Info       OpenApiInfo                      `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components OpenApiComponents                `json:"components"`
+}
+
+//-----------------------------------------------------------------------------
+
+type OpenApiInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+//-----------------------------------------------------------------------------
+
+type OpenApiComponents struct {
+	Schemas         map[string]Schema `json:"schemas"`
+	SecuritySchemes map[string]Schema `json:"securitySchemes"`
+}
+
+//-----------------------------------------------------------------------------
+
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security"`
+	Roles       []string              `json:"x-roles,omitempty"`
+	Permissions []string              `json:"x-permissions,omitempty"`
+}
+
+//-----------------------------------------------------------------------------
+
+type Parameter struct {
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required,omitempty"`
+	Schema   Schema `json:"schema"`
+}
+
+//-----------------------------------------------------------------------------
+
+type RequestBody struct {
+	Required bool              `json:"required"`
+	Content  map[string]*Media `json:"content"`
+}
+
+//-----------------------------------------------------------------------------
+
+type Response struct {
+	Description string            `json:"description"`
+	Content     map[string]*Media `json:"content,omitempty"`
+}
+
+//-----------------------------------------------------------------------------
+
+type Media struct {
+	Schema Schema `json:"schema"`
+}
+
+//=============================================================================
+
+const (
+	listResponseSchema  = "ListResponse"
+	errorResponseSchema = "ErrorResponse"
+	bearerScheme        = "bearer"
+)
+
+//=============================================================================
+
+var timeType = reflect.TypeFor[time.Time]()
+
+//=============================================================================
+
+type schemaBuilder struct {
+	schemas map[string]Schema
+	names   map[string]string
+}
+
+//=============================================================================
+//===
+//=== Public functions
+//===
+//=============================================================================
+
+func (r *Router) OpenApi() *OpenApiDocument {
+	sb := &schemaBuilder{
+		schemas: map[string]Schema{
+			listResponseSchema : listResponse(),
+			errorResponseSchema: errorResponse(),
+		},
+		names: map[string]string{},
+	}
+
+	doc := &OpenApiDocument{
+		OpenApi: "3.0.3",
+		Info   : OpenApiInfo{ Title: r.title, Version: r.version },
+		Paths  : map[string]map[string]*Operation{},
+		Components: OpenApiComponents{
+			Schemas        : sb.schemas,
+			SecuritySchemes: map[string]Schema{
+				bearerScheme: { "type": "http", "scheme": "bearer", "bearerFormat": "JWT" },
+			},
+		},
+	}
+
+	for _, ri := range r.Routes() {
+		path := openApiPath(ri.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*Operation{}
+		}
+
+		doc.Paths[path][strings.ToLower(ri.Method)] = sb.operation(ri)
+	}
+
+	return doc
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (sb *schemaBuilder) operation(ri *RouteInfo) *Operation {
+	op := &Operation{
+		Summary  : ri.Summary,
+		Security : []map[string][]string{ { bearerScheme: {} } },
+		Responses: map[string]*Response{
+			"400"    : errorReply("Bad request"),
+			"401"    : errorReply("Authentication failed"),
+			"403"    : errorReply("Access denied"),
+			"default": errorReply("Unexpected error"),
+		},
+	}
+
+	for _, r := range ri.Roles {
+		op.Roles = append(op.Roles, string(r))
+	}
+
+	for _, p := range ri.Permissions {
+		op.Permissions = append(op.Permissions, string(p))
+	}
+
+	if ri.Input != nil {
+		sb.parameters(op, ri)
+	}
+
+	switch {
+	case ri.Output == nil:
+		op.Responses["200"] = &Response{ Description: "Success" }
+
+	case ri.List:
+		op.Responses["200"] = jsonReply("Paged list", Schema{
+			"allOf": []Schema{
+				schemaRef(listResponseSchema),
+				{
+					"type"      : "object",
+					"properties": Schema{
+						"result": Schema{ "type": "array", "items": sb.schema(ri.Output) },
+					},
+				},
+			},
+		})
+
+	default:
+		op.Responses["200"] = jsonReply("Success", sb.schema(ri.Output))
+	}
+
+	return op
+}
+
+//=============================================================================
+
+func (sb *schemaBuilder) parameters(op *Operation, ri *RouteInfo) {
+	t := derefType(ri.Input)
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	body := Schema{
+		"type"      : "object",
+		"properties": Schema{},
+	}
+	var required []string
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		mandatory := isRequired(f)
+
+		if name := req.TagName(f, "uri"); name != "" {
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name    : name,
+				In      : "path",
+				Required: true,
+				Schema  : sb.schema(f.Type),
+			})
+			continue
+		}
+
+		if name := req.TagName(f, "form"); name != "" {
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name    : name,
+				In      : "query",
+				Required: mandatory,
+				Schema  : sb.schema(f.Type),
+			})
+			continue
+		}
+
+		name := req.TagName(f, "json")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		body["properties"].(Schema)[name] = sb.schema(f.Type)
+		if mandatory {
+			required = append(required, name)
+		}
+	}
+
+	if len(body["properties"].(Schema)) == 0 || ri.Method == http.MethodGet {
+		return
+	}
+
+	if len(required) > 0 {
+		body["required"] = required
+	}
+
+	op.RequestBody = &RequestBody{
+		Required: true,
+		Content : map[string]*Media{ "application/json": { Schema: body } },
+	}
+}
+
+//=============================================================================
+
+func (sb *schemaBuilder) schema(t reflect.Type) Schema {
+	t = derefType(t)
+
+	if t == timeType {
+		return Schema{ "type": "string", "format": "date-time" }
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return Schema{ "type": "boolean" }
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{ "type": "integer" }
+
+	case reflect.Float32, reflect.Float64:
+		return Schema{ "type": "number" }
+
+	case reflect.String:
+		return Schema{ "type": "string" }
+
+	case reflect.Slice, reflect.Array:
+		return Schema{ "type": "array", "items": sb.schema(t.Elem()) }
+
+	case reflect.Map:
+		return Schema{ "type": "object", "additionalProperties": sb.schema(t.Elem()) }
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return sb.object(t)
+		}
+
+		key  := t.PkgPath() +"."+ t.Name()
+		name, ok := sb.names[key]
+		if !ok {
+			name = sb.schemaName(t)
+			sb.names[key] = name
+
+			//--- Placeholder first, to stop recursion on self-referencing types
+			sb.schemas[name] = Schema{}
+			sb.schemas[name] = sb.object(t)
+		}
+
+		return schemaRef(name)
+	}
+
+	return Schema{}
+}
+
+//=============================================================================
+//--- Types with the same name in different packages get the package path as a
+//--- prefix. Characters not allowed in component names are replaced by '_'
+
+func (sb *schemaBuilder) schemaName(t reflect.Type) string {
+	name := componentName(t.Name())
+	if _, used := sb.schemas[name]; !used {
+		return name
+	}
+
+	return componentName(t.PkgPath() +"."+ t.Name())
+}
+
+//=============================================================================
+
+func (sb *schemaBuilder) object(t reflect.Type) Schema {
+	properties := Schema{}
+	var required []string
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		name := req.TagName(f, "json")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		properties[name] = sb.schema(f.Type)
+		if isRequired(f) {
+			required = append(required, name)
+		}
+	}
+
+	s := Schema{
+		"type"      : "object",
+		"properties": properties,
+	}
+
+	if len(required) > 0 {
+		s["required"] = required
+	}
+
+	return s
+}
+
+//=============================================================================
+
+func listResponse() Schema {
+	return Schema{
+		"type"      : "object",
+		"properties": Schema{
+			"offset"  : Schema{ "type": "integer" },
+			"limit"   : Schema{ "type": "integer" },
+			"overflow": Schema{ "type": "boolean" },
+			"result"  : Schema{ "type": "array", "items": Schema{} },
+		},
+		"required": []string{ "offset", "limit", "overflow", "result" },
+	}
+}
+
+//=============================================================================
+
+func errorResponse() Schema {
+	return Schema{
+		"type"      : "object",
+		"properties": Schema{
+			"code"  : Schema{ "type": "integer" },
+			"error" : Schema{ "type": "string" },
+			"reason": Schema{ "type": "string" },
+		},
+		"required": []string{ "code", "error" },
+	}
+}
+
+//=============================================================================
+
+func jsonReply(description string, schema Schema) *Response {
+	return &Response{
+		Description: description,
+		Content    : map[string]*Media{ "application/json": { Schema: schema } },
+	}
+}
+
+//=============================================================================
+
+func errorReply(description string) *Response {
+	return jsonReply(description, schemaRef(errorResponseSchema))
+}
+
+//=============================================================================
+
+func schemaRef(name string) Schema {
+	return Schema{ "$ref": "#/components/schemas/"+ name }
+}
+
+//=============================================================================
+
+func componentName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+//=============================================================================
+//--- Converts gin's ':id' and '*path' segments into '{id}' and '{path}'
+
+func openApiPath(path string) string {
+	segments := strings.Split(path, "/")
+
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "{"+ s[1:] +"}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+//=============================================================================
+
+func isRequired(f reflect.StructField) bool {
+	return slices.Contains(strings.Split(f.Tag.Get("binding"), ","), "required")
+}
+
+//=============================================================================
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return t
+}
+
+//=============================================================================
@@ -0,0 +1,121 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/bit-fever/core/req"
+)
+
+//=============================================================================
+
+type Owned interface {
+	GetOwner() string
+}
+
+//=============================================================================
+//--- Roles listed here bypass the ownership check, unless they act on behalf
+//--- of another user: in that case the represented user must be the owner
+
+type OwnershipPolicy struct {
+	Overrides []role.Role
+}
+
+//=============================================================================
+
+var DefaultOwnershipPolicy = &OwnershipPolicy{
+	Overrides: roles.Admin_Service,
+}
+
+//=============================================================================
+
+var StrictOwnershipPolicy = &OwnershipPolicy{}
+
+//=============================================================================
+//===
+//=== Public functions
+//===
+//=============================================================================
+
+func (op *OwnershipPolicy) CanAccess(us *UserSession, owner string) bool {
+	if !us.IsDelegated() && us.IsUserInRole(op.Overrides) {
+		return true
+	}
+
+	return owner != "" && owner == us.OnBehalfOf
+}
+
+//=============================================================================
+
+func (op *OwnershipPolicy) Check(us *UserSession, entity Owned) error {
+	return op.CheckOwner(us, entity.GetOwner())
+}
+
+//=============================================================================
+
+func (op *OwnershipPolicy) CheckOwner(us *UserSession, owner string) error {
+	if op.CanAccess(us, owner) {
+		return nil
+	}
+
+	return req.NewForbiddenError("User not allowed to access a resource of another user: %v", us.OnBehalfOf)
+}
+
+//=============================================================================
+//--- Returns all=true when the caller can see all entities, otherwise the owner
+//--- that list queries must be restricted to. An empty owner matches nothing
+
+func (op *OwnershipPolicy) OwnerFilter(us *UserSession) (all bool, owner string) {
+	if !us.IsDelegated() && us.IsUserInRole(op.Overrides) {
+		return true, ""
+	}
+
+	return false, us.OnBehalfOf
+}
+
+//=============================================================================
+
+func FilterOwned[T Owned](op *OwnershipPolicy, us *UserSession, entities []T) []T {
+	all, owner := op.OwnerFilter(us)
+	if all {
+		return entities
+	}
+
+	if owner == "" {
+		return nil
+	}
+
+	var result []T
+	for _, e := range entities {
+		if e.GetOwner() == owner {
+			result = append(result, e)
+		}
+	}
+
+	return result
+}
+
+//=============================================================================
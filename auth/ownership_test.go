@@ -0,0 +1,129 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"errors"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/req"
+	"net/http"
+	"testing"
+)
+
+//=============================================================================
+
+type tradingSystem struct {
+	Name     string
+	Username string
+}
+
+//-----------------------------------------------------------------------------
+
+func (ts *tradingSystem) GetOwner() string {
+	return ts.Username
+}
+
+//=============================================================================
+
+func testSession(username string, onBehalfOf string, userRoles ...role.Role) *UserSession {
+	us := &UserSession{
+		Username  : username,
+		OnBehalfOf: onBehalfOf,
+		Roles     : map[role.Role]any{},
+	}
+
+	for _, r := range userRoles {
+		us.Roles[r] = nil
+	}
+
+	return us
+}
+
+//=============================================================================
+
+func TestOwnershipPolicy(t *testing.T) {
+	entity := &tradingSystem{ Name: "es-breakout", Username: "alice" }
+
+	cases := []struct {
+		policy  *OwnershipPolicy
+		session *UserSession
+		allowed bool
+		all     bool
+		filter  string
+	}{
+		{ DefaultOwnershipPolicy, testSession("alice",     "alice",     role.User),    true,  false, "alice" },
+		{ DefaultOwnershipPolicy, testSession("bob",       "bob",       role.User),    false, false, "bob"   },
+		{ DefaultOwnershipPolicy, testSession("root",      "root",      role.Admin),   true,  true,  ""      },
+		{ DefaultOwnershipPolicy, testSession("inventory", "inventory", role.Service), true,  true,  ""      },
+		{ DefaultOwnershipPolicy, testSession("inventory", "alice",     role.Service), true,  false, "alice" },
+		{ DefaultOwnershipPolicy, testSession("inventory", "bob",       role.Service), false, false, "bob"   },
+		{ StrictOwnershipPolicy,  testSession("root",      "root",      role.Admin),   false, false, "root"  },
+		{ DefaultOwnershipPolicy, testSession("",          "",          role.User),    false, false, ""      },
+	}
+
+	for i, tc := range cases {
+		err := tc.policy.Check(tc.session, entity)
+		if (err == nil) != tc.allowed {
+			t.Errorf("Case %d: expected allowed=%v but got %v", i, tc.allowed, err)
+		}
+
+		var ae req.AppError
+		if err != nil && (!errors.As(err, &ae) || ae.Code != http.StatusForbidden) {
+			t.Errorf("Case %d: expected a forbidden error but got %v", i, err)
+		}
+
+		if all, filter := tc.policy.OwnerFilter(tc.session); all != tc.all || filter != tc.filter {
+			t.Errorf("Case %d: expected filter %v/'%s' but got %v/'%s'", i, tc.all, tc.filter, all, filter)
+		}
+	}
+
+	if DefaultOwnershipPolicy.CanAccess(testSession("", "", role.User), "") {
+		t.Errorf("An empty owner must not match an anonymous session")
+	}
+}
+
+//=============================================================================
+
+func TestFilterOwned(t *testing.T) {
+	systems := []*tradingSystem{
+		{ Name: "a", Username: "alice" },
+		{ Name: "b", Username: "bob"   },
+		{ Name: "c", Username: "alice" },
+	}
+
+	if result := FilterOwned(DefaultOwnershipPolicy, testSession("alice", "alice", role.User), systems); len(result) != 2 || result[1].Name != "c" {
+		t.Errorf("Unexpected filtered list: %v", result)
+	}
+
+	if result := FilterOwned(DefaultOwnershipPolicy, testSession("root", "root", role.Admin), systems); len(result) != 3 {
+		t.Errorf("Unexpected filtered list: %v", result)
+	}
+
+	if result := FilterOwned(DefaultOwnershipPolicy, testSession("", "", role.User), systems); len(result) != 0 {
+		t.Errorf("A session without a username must see nothing: %v", result)
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,146 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"errors"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"strings"
+)
+
+//=============================================================================
+
+type Permission string
+
+//=============================================================================
+
+const AllPermissions Permission = "*"
+
+//=============================================================================
+
+type PermissionModel struct {
+	roles map[role.Role]map[Permission]any
+}
+
+//=============================================================================
+
+var EmptyPermissionModel = &PermissionModel{
+	roles: map[role.Role]map[Permission]any{},
+}
+
+//=============================================================================
+//===
+//=== Public functions
+//===
+//=============================================================================
+
+func NewPermissionModel(cfg *core.Authorization) (*PermissionModel, error) {
+	if cfg == nil {
+		return EmptyPermissionModel, nil
+	}
+
+	defs := map[role.Role]*core.RolePermissions{}
+	for i := range cfg.Roles {
+		rp := &cfg.Roles[i]
+		defs[role.Role(rp.Role)] = rp
+	}
+
+	pm := &PermissionModel{
+		roles: map[role.Role]map[Permission]any{},
+	}
+
+	for r := range defs {
+		perms := map[Permission]any{}
+		if err := collectPermissions(defs, r, perms, map[role.Role]bool{}); err != nil {
+			return nil, err
+		}
+		pm.roles[r] = perms
+	}
+
+	return pm, nil
+}
+
+//=============================================================================
+
+func (pm *PermissionModel) Resolve(userRoles map[role.Role]any) map[Permission]any {
+	perms := map[Permission]any{}
+
+	for r := range userRoles {
+		for p := range pm.roles[r] {
+			perms[p] = nil
+		}
+	}
+
+	return perms
+}
+
+//=============================================================================
+
+func (p Permission) Matches(granted Permission) bool {
+	if granted == p || granted == AllPermissions {
+		return true
+	}
+
+	//--- A permission like 'portfolio:*' grants all 'portfolio:...' permissions
+
+	prefix, found := strings.CutSuffix(string(granted), ":*")
+
+	return found && strings.HasPrefix(string(p), prefix +":")
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func collectPermissions(defs map[role.Role]*core.RolePermissions, r role.Role, perms map[Permission]any, visiting map[role.Role]bool) error {
+	if visiting[r] {
+		return errors.New("cyclic role inheritance found for role: "+ string(r))
+	}
+
+	rp, ok := defs[r]
+	if !ok {
+		return errors.New("inherited role is not defined: "+ string(r))
+	}
+
+	visiting[r] = true
+	defer delete(visiting, r)
+
+	for _, p := range rp.Permissions {
+		perms[Permission(p)] = nil
+	}
+
+	for _, parent := range rp.Inherits {
+		if err := collectPermissions(defs, role.Role(parent), perms, visiting); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//=============================================================================
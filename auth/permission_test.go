@@ -0,0 +1,137 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/req"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing"
+)
+
+//=============================================================================
+
+var testAuthorization = &core.Authorization{
+	Roles: []core.RolePermissions{
+		{ Role: "user",    Permissions: []string{ "portfolio:read", "trading-system:read" }},
+		{ Role: "trader",  Permissions: []string{ "trading-system:activate" }, Inherits: []string{ "user" }},
+		{ Role: "admin",   Permissions: []string{ "portfolio:*" },             Inherits: []string{ "trader" }},
+		{ Role: "service", Permissions: []string{ "*" }},
+	},
+}
+
+//=============================================================================
+
+func TestPermissionModel(t *testing.T) {
+	pm, err := NewPermissionModel(testAuthorization)
+	if err != nil {
+		t.Fatalf("NewPermissionModel failed: %v", err)
+	}
+
+	cases := []struct {
+		role       role.Role
+		permission Permission
+		expected   bool
+	}{
+		{ "user",    "portfolio:read",          true  },
+		{ "user",    "portfolio:write",         false },
+		{ "trader",  "trading-system:activate", true  },
+		{ "trader",  "portfolio:read",          true  },
+		{ "admin",   "portfolio:write",         true  },
+		{ "admin",   "trading-system:activate", true  },
+		{ "admin",   "inventory:write",         false },
+		{ "service", "inventory:write",         true  },
+		{ "unknown", "portfolio:read",          false },
+	}
+
+	for _, tc := range cases {
+		us := &UserSession{ Roles: map[role.Role]any{ tc.role: nil }}
+		us.Permissions = pm.Resolve(us.Roles)
+
+		if us.Can(tc.permission) != tc.expected {
+			t.Errorf("Role '%v', permission '%v': expected %v", tc.role, tc.permission, tc.expected)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestPermissionModelErrors(t *testing.T) {
+	configs := map[string]*core.Authorization{
+		"cycle": { Roles: []core.RolePermissions{
+			{ Role: "a", Inherits: []string{ "b" }},
+			{ Role: "b", Inherits: []string{ "a" }},
+		}},
+		"undefined": { Roles: []core.RolePermissions{
+			{ Role: "a", Inherits: []string{ "missing" }},
+		}},
+	}
+
+	for name, cfg := range configs {
+		if _, err := NewPermissionModel(cfg); err == nil {
+			t.Errorf("Expected an error for the '%v' configuration", name)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestSecureWithPermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	pm, _ := NewPermissionModel(testAuthorization)
+	key   := newTestKey(t)
+	oc    := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	oc.SetPermissionModel(pm)
+
+	router := gin.New()
+	router.POST("/activate", oc.SecureWith(func(c *Context) {
+		_ = c.ReturnObject("ok")
+	}, []Permission{ "trading-system:activate" }, WithDelegation(&DelegationPolicy{ Users: []string{ "bob" } })))
+
+	if rr := serve(router, "POST", "/activate", mintToken(t, key, userClaims("bob", "user")), nil); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d but got %d", http.StatusForbidden, rr.Code)
+	}
+
+	if rr := serve(router, "POST", "/activate", mintToken(t, key, userClaims("bob", "trader")), nil); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	//--- Route options are applied
+
+	if rr := serve(router, "POST", "/activate", mintToken(t, key, userClaims("bob", "trader")), map[string]string{ req.OnBehalfOf: "alice" }); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	//--- An empty permission list is rejected at registration
+
+	if err := checkPermissions(nil); err == nil {
+		t.Errorf("Expected an empty permission list to be rejected")
+	}
+}
+
+//=============================================================================
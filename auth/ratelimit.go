@@ -0,0 +1,297 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"container/list"
+	"errors"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"sync"
+	"time"
+)
+
+//=============================================================================
+
+const (
+	RateLimitByUsername = "username"
+	RateLimitByIp       = "ip"
+)
+
+//=============================================================================
+
+const maxBuckets = 10000
+
+//=============================================================================
+
+type RateLimiter struct {
+	sync.Mutex
+	keyBy   string
+	global  *core.RateLimit
+	roles   []core.RoleRateLimit
+	routes  map[string]*core.RateLimit
+	buckets map[string]*list.Element
+	lru     *list.List
+}
+
+//=============================================================================
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+	limit  *core.RateLimit
+}
+
+//=============================================================================
+//===
+//=== Public functions
+//===
+//=============================================================================
+
+func NewRateLimiter(cfg *core.RateLimiting) (*RateLimiter, error) {
+	rl := newRateLimiter()
+	rl.roles = cfg.Roles
+
+	switch cfg.KeyBy {
+	case "", RateLimitByUsername:
+	case RateLimitByIp:
+		rl.keyBy = RateLimitByIp
+	default:
+		return nil, errors.New("invalid rate limiting key: "+ cfg.KeyBy)
+	}
+
+	if cfg.Default.Requests > 0 {
+		rl.global = &cfg.Default
+	}
+
+	for i := range cfg.Roles {
+		if err := checkRateLimit(&cfg.Roles[i].RateLimit, cfg.Roles[i].Exempt); err != nil {
+			return nil, errors.New("role '"+ cfg.Roles[i].Role +"': "+ err.Error())
+		}
+	}
+
+	for i := range cfg.Routes {
+		r := &cfg.Routes[i]
+		if err := checkRateLimit(&r.RateLimit, false); err != nil {
+			return nil, errors.New("route '"+ r.Route +"': "+ err.Error())
+		}
+		rl.routes[r.Route] = &r.RateLimit
+	}
+
+	if err := checkRateLimit(&cfg.Default, cfg.Default.Requests == 0); err != nil {
+		return nil, errors.New("default: "+ err.Error())
+	}
+
+	return rl, nil
+}
+
+//=============================================================================
+
+func (rl *RateLimiter) Allow(us *UserSession, clientIp string, route string, routeLimit *core.RateLimit) (bool, time.Duration) {
+	globalLimit, exempt := rl.limitForRoles(us.Roles)
+	if exempt {
+		return true, 0
+	}
+
+	key := us.Username
+	if rl.keyBy == RateLimitByIp || key == "" {
+		key = clientIp
+	}
+
+	if routeLimit == nil {
+		routeLimit = rl.routes[route]
+	}
+
+	if routeLimit == nil && globalLimit == nil {
+		return true, 0
+	}
+
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := time.Now()
+
+	if routeLimit != nil {
+		if ok, wait := rl.take(key +"|"+ route, routeLimit, now); !ok {
+			return false, wait
+		}
+	}
+
+	if globalLimit != nil {
+		if ok, wait := rl.take(key, globalLimit, now); !ok {
+			return false, wait
+		}
+	}
+
+	return true, 0
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func newRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		keyBy  : RateLimitByUsername,
+		routes : map[string]*core.RateLimit{},
+		buckets: map[string]*list.Element{},
+		lru    : list.New(),
+	}
+}
+
+//=============================================================================
+//--- Users with several roles get the most permissive of their limits
+
+func (rl *RateLimiter) limitForRoles(userRoles map[role.Role]any) (*core.RateLimit, bool) {
+	var limit *core.RateLimit
+
+	for i := range rl.roles {
+		rr := &rl.roles[i]
+		if _, ok := userRoles[role.Role(rr.Role)]; !ok {
+			continue
+		}
+
+		if rr.Exempt {
+			return nil, true
+		}
+
+		if limit == nil || isMorePermissive(&rr.RateLimit, limit) {
+			limit = &rr.RateLimit
+		}
+	}
+
+	if limit == nil {
+		return rl.global, false
+	}
+
+	return limit, false
+}
+
+//=============================================================================
+
+func (rl *RateLimiter) take(key string, limit *core.RateLimit, now time.Time) (bool, time.Duration) {
+	b := rl.bucket(key, limit, now)
+
+	rate := refillRate(limit)
+
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	b.last    = now
+	if capacity := float64(burst(limit)); b.tokens > capacity {
+		b.tokens = capacity
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+//=============================================================================
+//--- Buckets are kept in least recently used order, so that the oldest one
+//--- can be evicted in constant time when the limiter is full
+
+func (rl *RateLimiter) bucket(key string, limit *core.RateLimit, now time.Time) *bucket {
+	if e, ok := rl.buckets[key]; ok {
+		rl.lru.MoveToFront(e)
+
+		b := e.Value.(*bucket)
+		if b.limit == limit {
+			return b
+		}
+
+		b.tokens = float64(burst(limit))
+		b.last   = now
+		b.limit  = limit
+		return b
+	}
+
+	if rl.lru.Len() >= maxBuckets {
+		oldest := rl.lru.Back()
+		rl.lru.Remove(oldest)
+		delete(rl.buckets, oldest.Value.(*bucket).key)
+	}
+
+	b := &bucket{
+		key   : key,
+		tokens: float64(burst(limit)),
+		last  : now,
+		limit : limit,
+	}
+	rl.buckets[key] = rl.lru.PushFront(b)
+
+	return b
+}
+
+//=============================================================================
+
+func checkRateLimit(limit *core.RateLimit, optional bool) error {
+	if optional && limit.Requests == 0 {
+		return nil
+	}
+
+	if limit.Requests <= 0 || limit.Period <= 0 {
+		return errors.New("rate limit requires positive 'requests' and 'period'")
+	}
+
+	if limit.Burst < 0 {
+		return errors.New("rate limit burst cannot be negative")
+	}
+
+	return nil
+}
+
+//=============================================================================
+
+func refillRate(limit *core.RateLimit) float64 {
+	return float64(limit.Requests) / limit.Period.Seconds()
+}
+
+//=============================================================================
+
+func isMorePermissive(a *core.RateLimit, b *core.RateLimit) bool {
+	if ra, rb := refillRate(a), refillRate(b); ra != rb {
+		return ra > rb
+	}
+
+	return burst(a) > burst(b)
+}
+
+//=============================================================================
+
+func burst(limit *core.RateLimit) int {
+	if limit.Burst > 0 {
+		return limit.Burst
+	}
+
+	return limit.Requests
+}
+
+//=============================================================================
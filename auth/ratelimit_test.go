@@ -0,0 +1,261 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"encoding/json"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+func TestSecureRateLimiting(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key := newTestKey(t)
+	oc  := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+
+	err := oc.SetRateLimiting(&core.RateLimiting{
+		Default: core.RateLimit{ Requests: 3, Period: time.Minute },
+		Roles  : []core.RoleRateLimit{
+			{ Role: string(role.Service), Exempt: true },
+		},
+		Routes : []core.RouteRateLimit{
+			{ Route: "POST /orders", RateLimit: core.RateLimit{ Requests: 1, Period: time.Hour }},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SetRateLimiting failed: %v", err)
+	}
+
+	handler := func(c *Context) {
+		_ = c.ReturnObject("ok")
+	}
+
+	router := gin.New()
+	router.GET ("/portfolio", oc.Secure(handler, roles.Admin_User_Service))
+	router.POST("/orders",    oc.Secure(handler, roles.Admin_User_Service))
+
+	alice   := mintToken(t, key, userClaims("alice",     role.User))
+	bob     := mintToken(t, key, userClaims("bob",       role.User))
+	service := mintToken(t, key, userClaims("inventory", role.Service))
+
+	for i := 0; i < 3; i++ {
+		if rr := serve(router, "GET", "/portfolio", alice, nil); rr.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected status %d but got %d", i, http.StatusOK, rr.Code)
+		}
+	}
+
+	rr := serve(router, "GET", "/portfolio", alice, nil)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d but got %d", http.StatusTooManyRequests, rr.Code)
+	}
+
+	if rr.Header().Get("Retry-After") == "" {
+		t.Errorf("Missing the Retry-After header")
+	}
+
+	var body struct { Code int `json:"code"`; Error string `json:"error"` }
+	if err = json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Code != http.StatusTooManyRequests {
+		t.Errorf("Unexpected error body: %s", rr.Body.String())
+	}
+
+	//--- Budgets are per user, per route, and the service role is exempt
+
+	if rr = serve(router, "GET", "/portfolio", bob, nil); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	if rr = serve(router, "POST", "/orders", bob, nil); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	if rr = serve(router, "POST", "/orders", bob, nil); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d but got %d", http.StatusTooManyRequests, rr.Code)
+	}
+
+	for i := 0; i < 10; i++ {
+		if rr = serve(router, "GET", "/portfolio", service, nil); rr.Code != http.StatusOK {
+			t.Fatalf("Service request %d: expected status %d but got %d", i, http.StatusOK, rr.Code)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestRateLimiterRefill(t *testing.T) {
+	rl, err := NewRateLimiter(&core.RateLimiting{
+		KeyBy  : RateLimitByIp,
+		Default: core.RateLimit{ Requests: 10, Period: time.Second, Burst: 1 },
+	})
+	if err != nil {
+		t.Fatalf("NewRateLimiter failed: %v", err)
+	}
+
+	us := &UserSession{ Username: "alice" }
+
+	if ok, _ := rl.Allow(us, "10.0.0.1", "", nil); !ok {
+		t.Fatalf("First request must be allowed")
+	}
+
+	ok, wait := rl.Allow(us, "10.0.0.1", "", nil)
+	if ok || wait <= 0 || wait > 100*time.Millisecond {
+		t.Fatalf("Expected a short wait but got ok=%v, wait=%v", ok, wait)
+	}
+
+	if ok, _ = rl.Allow(us, "10.0.0.2", "", nil); !ok {
+		t.Errorf("Requests from another IP must be allowed")
+	}
+
+	time.Sleep(wait + 10*time.Millisecond)
+	if ok, _ = rl.Allow(us, "10.0.0.1", "", nil); !ok {
+		t.Errorf("Request must be allowed after the refill")
+	}
+}
+
+//=============================================================================
+
+func TestRateLimiterConfigErrors(t *testing.T) {
+	configs := []*core.RateLimiting{
+		{ KeyBy: "session" },
+		{ Default: core.RateLimit{ Requests: 10 }},
+		{ Roles: []core.RoleRateLimit{{ Role: "user" }}},
+		{ Routes: []core.RouteRateLimit{{ Route: "GET /x", RateLimit: core.RateLimit{ Requests: 1, Period: time.Second, Burst: -1 }}}},
+	}
+
+	for i, cfg := range configs {
+		if _, err := NewRateLimiter(cfg); err == nil {
+			t.Errorf("Case %d: expected an error", i)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestRateLimiterRoles(t *testing.T) {
+	rl, err := NewRateLimiter(&core.RateLimiting{
+		Roles: []core.RoleRateLimit{
+			{ Role: string(role.User),    RateLimit: core.RateLimit{ Requests: 1, Period: time.Hour }},
+			{ Role: "trader",             RateLimit: core.RateLimit{ Requests: 3, Period: time.Hour }},
+			{ Role: string(role.Service), Exempt: true },
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRateLimiter failed: %v", err)
+	}
+
+	trader  := &UserSession{ Username: "alice", Roles: map[role.Role]any{ role.User: nil, "trader": nil } }
+	service := &UserSession{ Username: "inventory", Roles: map[role.Role]any{ role.User: nil, role.Service: nil } }
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := rl.Allow(trader, "", "", nil); !ok {
+			t.Fatalf("Request %d: expected the most permissive limit to apply", i)
+		}
+	}
+
+	if ok, _ := rl.Allow(trader, "", "", nil); ok {
+		t.Errorf("Expected the limit to be reached")
+	}
+
+	for i := 0; i < 5; i++ {
+		if ok, _ := rl.Allow(service, "", "", nil); !ok {
+			t.Fatalf("Request %d: expected the service exemption to apply", i)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestRateLimiterEviction(t *testing.T) {
+	rl, err := NewRateLimiter(&core.RateLimiting{
+		KeyBy  : RateLimitByIp,
+		Default: core.RateLimit{ Requests: 1, Period: time.Hour },
+	})
+	if err != nil {
+		t.Fatalf("NewRateLimiter failed: %v", err)
+	}
+
+	us := &UserSession{}
+	rl.Allow(us, "first", "", nil)
+
+	for i := 0; i < maxBuckets; i++ {
+		rl.Allow(us, strconv.Itoa(i), "", nil)
+
+		//--- Keeps the first key in use
+		if i == maxBuckets / 2 {
+			rl.Allow(us, "first", "", nil)
+		}
+	}
+
+	if len(rl.buckets) != maxBuckets || rl.lru.Len() != maxBuckets {
+		t.Errorf("Expected %d buckets but got %d", maxBuckets, len(rl.buckets))
+	}
+
+	if _, ok := rl.buckets["first"]; !ok {
+		t.Errorf("A recently used bucket was evicted")
+	}
+
+	if _, ok := rl.buckets["0"]; ok {
+		t.Errorf("The least recently used bucket was not evicted")
+	}
+}
+
+//=============================================================================
+
+func TestRateLimiterIgnoresForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key := newTestKey(t)
+	oc  := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+
+	err := oc.SetRateLimiting(&core.RateLimiting{
+		KeyBy  : RateLimitByIp,
+		Default: core.RateLimit{ Requests: 1, Period: time.Hour },
+	})
+	if err != nil {
+		t.Fatalf("SetRateLimiting failed: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/api", oc.Secure(func(c *Context) {
+		_ = c.ReturnObject("ok")
+	}, roles.User))
+
+	token := mintToken(t, key, userClaims("alice", role.User))
+
+	serve(router, "GET", "/api", token, map[string]string{ "X-Forwarded-For": "10.0.0.1" })
+	if rr := serve(router, "GET", "/api", token, map[string]string{ "X-Forwarded-For": "10.0.0.2" }); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %d but got %d", http.StatusTooManyRequests, rr.Code)
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,338 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"github.com/bit-fever/core/msg"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+//=============================================================================
+
+const DefaultRevocationTTL = 24 * time.Hour
+
+//=============================================================================
+//--- Entries are kept for the registry TTL, which is enough for bearer tokens,
+//--- or until Expiry when the revoked credential lives longer. Permanent keeps
+//--- them for credentials that never expire
+
+type Revocation struct {
+	SessionID string
+	TokenID   string
+	Username  string
+	Issuer    string
+	Subject   string
+	RevokedAt time.Time
+	Expiry    time.Time
+	Permanent bool
+}
+
+//=============================================================================
+//--- One subscription to the auth exchange is shared by all listeners
+
+type authBroadcast struct {
+	sync.Mutex
+	handlers map[string][]func(m *msg.Message) bool
+	started  bool
+}
+
+//-----------------------------------------------------------------------------
+
+var authListeners = &authBroadcast{ handlers: map[string][]func(m *msg.Message) bool{} }
+
+//=============================================================================
+
+type RevocationRegistry struct {
+	sync.RWMutex
+	ttl       time.Duration
+	sessions  map[string]*revocationEntry
+	tokens    map[string]*revocationEntry
+	users     map[string]*revocationEntry
+	subjects  map[string]*revocationEntry
+	listeners []func(r *Revocation)
+	propagate bool
+}
+
+//-----------------------------------------------------------------------------
+//--- A zero keepUntil never expires
+
+type revocationEntry struct {
+	revokedAt time.Time
+	keepUntil time.Time
+}
+
+//=============================================================================
+//===
+//=== Public functions
+//===
+//=============================================================================
+
+func NewRevocationRegistry(ttl time.Duration) *RevocationRegistry {
+	if ttl <= 0 {
+		ttl = DefaultRevocationTTL
+	}
+
+	return &RevocationRegistry{
+		ttl     : ttl,
+		sessions: map[string]*revocationEntry{},
+		tokens  : map[string]*revocationEntry{},
+		users   : map[string]*revocationEntry{},
+		subjects: map[string]*revocationEntry{},
+	}
+}
+
+//=============================================================================
+
+func (rr *RevocationRegistry) RevokeSession(sessionId string) error {
+	return rr.Revoke(&Revocation{ SessionID: sessionId })
+}
+
+//=============================================================================
+
+func (rr *RevocationRegistry) RevokeToken(tokenId string) error {
+	return rr.Revoke(&Revocation{ TokenID: tokenId })
+}
+
+//=============================================================================
+
+func (rr *RevocationRegistry) RevokeUser(username string) error {
+	return rr.Revoke(&Revocation{ Username: username })
+}
+
+//=============================================================================
+//--- API keys can live longer than the registry TTL, or never expire
+
+func (rr *RevocationRegistry) RevokeApiKey(key *ApiKey) error {
+	return rr.Revoke(&Revocation{ SessionID: key.Id, Expiry: key.Expiry, Permanent: key.Expiry.IsZero() })
+}
+
+//=============================================================================
+//--- Revokes the user's certificates issued before now, until the given one expires
+
+func (rr *RevocationRegistry) RevokeCertificate(cert *x509.Certificate) error {
+	username := NewCertificateIdentity(cert).username()
+	if username == "" {
+		return errors.New("client certificate has no usable subject")
+	}
+
+	return rr.Revoke(&Revocation{ Username: username, Expiry: cert.NotAfter })
+}
+
+//=============================================================================
+
+func (rr *RevocationRegistry) Revoke(r *Revocation) error {
+	if r.RevokedAt.IsZero() {
+		r.RevokedAt = time.Now()
+	}
+
+	rr.apply(r)
+
+	rr.RLock()
+	propagate := rr.propagate
+	rr.RUnlock()
+
+	if !propagate {
+		return nil
+	}
+
+	return msg.SendMessage(msg.ExAuth, msg.SourceRevocation, msg.TypeCreate, r)
+}
+
+//=============================================================================
+
+func (rr *RevocationRegistry) IsRevoked(us *UserSession) bool {
+	rr.RLock()
+	defer rr.RUnlock()
+
+	if us.SessionID != "" {
+		if _, ok := rr.sessions[us.SessionID]; ok {
+			return true
+		}
+	}
+
+	if us.TokenID != "" {
+		if _, ok := rr.tokens[us.TokenID]; ok {
+			return true
+		}
+	}
+
+	//--- A user revocation invalidates only the tokens issued before it
+
+	if e, ok := rr.users[us.Username]; ok {
+		if us.IssuedAt.IsZero() || !us.IssuedAt.After(e.revokedAt) {
+			return true
+		}
+	}
+
+	if us.Subject != "" {
+		if e, ok := rr.subjects[subjectKey(us.Issuer, us.Subject)]; ok {
+			return us.IssuedAt.IsZero() || !us.IssuedAt.After(e.revokedAt)
+		}
+	}
+
+	return false
+}
+
+//=============================================================================
+
+func (rr *RevocationRegistry) AddListener(listener func(r *Revocation)) {
+	rr.Lock()
+	defer rr.Unlock()
+
+	rr.listeners = append(rr.listeners, listener)
+}
+
+//=============================================================================
+
+func (rr *RevocationRegistry) EnablePropagation() {
+	rr.Lock()
+	rr.propagate = true
+	rr.Unlock()
+
+	listenAuthBroadcast(msg.SourceRevocation, rr.onMessage)
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (rr *RevocationRegistry) apply(r *Revocation) {
+	rr.Lock()
+
+	now := time.Now()
+	rr.purge(now)
+
+	e := &revocationEntry{
+		revokedAt: r.RevokedAt,
+		keepUntil: r.RevokedAt.Add(rr.ttl),
+	}
+
+	if r.Permanent {
+		e.keepUntil = time.Time{}
+	} else if r.Expiry.After(e.keepUntil) {
+		e.keepUntil = r.Expiry
+	}
+
+	if r.SessionID != "" {
+		rr.sessions[r.SessionID] = e
+	}
+
+	if r.TokenID != "" {
+		rr.tokens[r.TokenID] = e
+	}
+
+	if r.Username != "" {
+		rr.users[r.Username] = e
+	}
+
+	if r.Subject != "" {
+		rr.subjects[subjectKey(r.Issuer, r.Subject)] = e
+	}
+
+	listeners := rr.listeners
+	rr.Unlock()
+
+	for _, l := range listeners {
+		l(r)
+	}
+}
+
+//=============================================================================
+
+func (rr *RevocationRegistry) purge(now time.Time) {
+	for _, entries := range []map[string]*revocationEntry{ rr.sessions, rr.tokens, rr.users, rr.subjects } {
+		for k, e := range entries {
+			if !e.keepUntil.IsZero() && now.After(e.keepUntil) {
+				delete(entries, k)
+			}
+		}
+	}
+}
+
+//=============================================================================
+
+func (rr *RevocationRegistry) onMessage(m *msg.Message) bool {
+	if m.Source != msg.SourceRevocation {
+		return true
+	}
+
+	var r Revocation
+	if err := json.Unmarshal(m.Entity, &r); err != nil {
+		slog.Error("Cannot unmarshal revocation message. Skipping", "error", err.Error())
+		return true
+	}
+
+	rr.apply(&r)
+	return true
+}
+
+//=============================================================================
+//--- Subjects are only unique within their issuer
+
+func subjectKey(issuer string, subject string) string {
+	return issuer +"|"+ subject
+}
+
+//=============================================================================
+
+func listenAuthBroadcast(source string, handler func(m *msg.Message) bool) {
+	authListeners.Lock()
+	defer authListeners.Unlock()
+
+	authListeners.handlers[source] = append(authListeners.handlers[source], handler)
+
+	if !authListeners.started {
+		authListeners.started = true
+		go msg.ReceiveBroadcast(msg.ExAuth, dispatchAuthBroadcast)
+	}
+}
+
+//=============================================================================
+//--- Every handler gets the message: a failure in one of them requeues it
+
+func dispatchAuthBroadcast(m *msg.Message) bool {
+	authListeners.Lock()
+	handlers := slices.Clone(authListeners.handlers[m.Source])
+	authListeners.Unlock()
+
+	ok := true
+	for _, handler := range handlers {
+		if !handler(m) {
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+//=============================================================================
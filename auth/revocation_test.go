@@ -0,0 +1,254 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/bit-fever/core/msg"
+	"github.com/gin-gonic/gin"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+func TestRevocationRegistry(t *testing.T) {
+	rr  := NewRevocationRegistry(time.Hour)
+	now := time.Now()
+
+	_ = rr.RevokeSession("sid-1")
+	_ = rr.RevokeToken("jti-1")
+	_ = rr.Revoke(&Revocation{ Username: "alice", RevokedAt: now })
+	_ = rr.Revoke(&Revocation{ Issuer: testIssuer, Subject: "carol-id", RevokedAt: now })
+
+	cases := []struct {
+		session  *UserSession
+		expected bool
+	}{
+		{ &UserSession{ SessionID: "sid-1", Username: "bob" },                                true  },
+		{ &UserSession{ SessionID: "sid-2", TokenID: "jti-1", Username: "bob" },              true  },
+		{ &UserSession{ SessionID: "sid-2", TokenID: "jti-2", Username: "bob" },              false },
+		{ &UserSession{ Username: "alice", IssuedAt: now.Add(-time.Minute) },                 true  },
+		{ &UserSession{ Username: "alice", IssuedAt: now.Add( time.Minute) },                 false },
+		{ &UserSession{ Issuer: testIssuer, Subject: "carol-id", IssuedAt: now },             true  },
+		{ &UserSession{ Issuer: "partner",  Subject: "carol-id", IssuedAt: now },             false },
+	}
+
+	for i, tc := range cases {
+		if rr.IsRevoked(tc.session) != tc.expected {
+			t.Errorf("Case %d: expected revoked=%v", i, tc.expected)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestRevocationRetention(t *testing.T) {
+	rr   := NewRevocationRegistry(time.Hour)
+	past := time.Now().Add(-2 * time.Hour)
+
+	cert := &x509.Certificate{
+		Subject     : pkix.Name{ CommonName: "inventory" },
+		NotBefore   : past.Add(-time.Hour),
+		NotAfter    : time.Now().Add(24 * time.Hour),
+		SerialNumber: big.NewInt(1),
+	}
+
+	_ = rr.Revoke(&Revocation{ SessionID: "sid-1", RevokedAt: past })
+	_ = rr.Revoke(&Revocation{ SessionID: "key-1", RevokedAt: past, Permanent: true })
+	_ = rr.Revoke(&Revocation{ SessionID: "key-2", RevokedAt: past, Expiry: time.Now().Add(time.Hour) })
+
+	if err := rr.RevokeCertificate(cert); err != nil {
+		t.Fatalf("RevokeCertificate failed: %v", err)
+	}
+
+	if err := rr.RevokeApiKey(&ApiKey{ Id: "key-3" }); err != nil {
+		t.Fatalf("RevokeApiKey failed: %v", err)
+	}
+
+	//--- Two hours later: only the entries of long-lived credentials are left
+
+	rr.Lock()
+	rr.purge(time.Now().Add(2 * time.Hour))
+	rr.Unlock()
+
+	cases := []struct {
+		session  *UserSession
+		expected bool
+	}{
+		{ &UserSession{ SessionID: "sid-1" },                                   false },
+		{ &UserSession{ SessionID: "key-1" },                                   true  },
+		{ &UserSession{ SessionID: "key-2" },                                   false },
+		{ &UserSession{ SessionID: "key-3" },                                   true  },
+		{ &UserSession{ Username: "inventory", IssuedAt: cert.NotBefore },      true  },
+	}
+
+	for i, tc := range cases {
+		if rr.IsRevoked(tc.session) != tc.expected {
+			t.Errorf("Case %d: expected revoked=%v", i, tc.expected)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestRevocationBroadcast(t *testing.T) {
+	authListeners.Lock()
+	saved, started := authListeners.handlers, authListeners.started
+	authListeners.handlers = map[string][]func(m *msg.Message) bool{}
+	authListeners.started  = true
+	authListeners.Unlock()
+
+	t.Cleanup(func() {
+		authListeners.Lock()
+		authListeners.handlers, authListeners.started = saved, started
+		authListeners.Unlock()
+	})
+
+	//--- Every registry in the process gets the broadcast revocations
+
+	first  := NewRevocationRegistry(time.Hour)
+	second := NewRevocationRegistry(time.Hour)
+	first .EnablePropagation()
+	second.EnablePropagation()
+
+	entity, _ := json.Marshal(&Revocation{ SessionID: "sid-1", RevokedAt: time.Now() })
+	dispatchAuthBroadcast(&msg.Message{ Source: msg.SourceRevocation, Type: msg.TypeCreate, Entity: entity })
+
+	for i, rr := range []*RevocationRegistry{ first, second } {
+		if !rr.IsRevoked(&UserSession{ SessionID: "sid-1" }) {
+			t.Errorf("Registry %d: expected the session to be revoked", i)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestBackChannelLogout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key := newTestKey(t)
+	oc  := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+
+	router := gin.New()
+	router.POST("/logout", oc.BackChannelLogout())
+	router.GET ("/api",    oc.Secure(func(c *Context) {
+		_ = c.ReturnObject("ok")
+	}, roles.User))
+
+	claims := userClaims("alice", role.User)
+	claims["sid"] = "session-42"
+	token := mintToken(t, key, claims)
+
+	if rr := serve(router, "GET", "/api", token, nil); rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	post := func(logoutToken string) int {
+		form := url.Values{ "logout_token": { logoutToken }}
+		rq   := httptest.NewRequest("POST", "/logout", strings.NewReader(form.Encode()))
+		rq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, rq)
+		return rr.Code
+	}
+
+	//--- A regular access token is not a logout token
+
+	if code := post(token); code != http.StatusBadRequest {
+		t.Errorf("Expected status %d but got %d", http.StatusBadRequest, code)
+	}
+
+	logoutToken := mintToken(t, key, map[string]any{
+		"jti"   : "logout-1",
+		"sid"   : "session-42",
+		"events": map[string]any{ BackChannelLogoutEvent: map[string]any{} },
+	})
+
+	if code := post(logoutToken); code != http.StatusOK {
+		t.Fatalf("Expected status %d but got %d", http.StatusOK, code)
+	}
+
+	if rr := serve(router, "GET", "/api", token, nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d but got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	//--- Replays are rejected
+
+	if code := post(logoutToken); code != http.StatusBadRequest {
+		t.Errorf("Expected status %d but got %d", http.StatusBadRequest, code)
+	}
+
+	//--- Without 'sid' the user is identified by 'sub', never by username
+
+	other := mintToken(t, key, map[string]any{ "sub": "bob-id", "preferred_username": "bob", "realm_access": map[string]any{ "roles": []string{ "user" } } })
+
+	byUsername := mintToken(t, key, map[string]any{
+		"jti"               : "logout-2",
+		"sub"               : "",
+		"preferred_username": "bob",
+		"events"            : map[string]any{ BackChannelLogoutEvent: map[string]any{} },
+	})
+
+	if code := post(byUsername); code != http.StatusBadRequest {
+		t.Errorf("Expected status %d but got %d", http.StatusBadRequest, code)
+	}
+
+	bySubject := mintToken(t, key, map[string]any{
+		"jti"   : "logout-3",
+		"sub"   : "bob-id",
+		"events": map[string]any{ BackChannelLogoutEvent: map[string]any{} },
+	})
+
+	if code := post(bySubject); code != http.StatusOK {
+		t.Fatalf("Expected status %d but got %d", http.StatusOK, code)
+	}
+
+	if rr := serve(router, "GET", "/api", other, nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d but got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+//=============================================================================
+
+func TestBackChannelLogoutWithIntrospection(t *testing.T) {
+	oc := NewOidcControllerWithVerifier(NewIntrospectionVerifierWithEndpoint("http://localhost/introspect", &core.Authentication{}, http.DefaultClient), newTestLogger(), nil)
+
+	if oc.canVerifyLogout() {
+		t.Errorf("Expected back-channel logout to be refused with introspection only")
+	}
+}
+
+//=============================================================================
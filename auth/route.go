@@ -0,0 +1,235 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"errors"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"time"
+)
+
+//=============================================================================
+
+type RouteOption func(rc *routeConfig)
+
+//=============================================================================
+//--- Unset (nil) settings follow the controller's current ones
+
+type routeConfig struct {
+	roles       []role.Role
+	roleCheck   bool
+	permissions []Permission
+	delegation  *DelegationPolicy
+	certMode    *CertificateMode
+	apiKey      *bool
+	validations []*core.TokenValidation
+	rateLimit   *core.RateLimit
+	audit       *bool
+	timeout     time.Duration
+}
+
+//=============================================================================
+//===
+//=== Options
+//===
+//=============================================================================
+
+func WithPermissions(permissions ...Permission) RouteOption {
+	return func(rc *routeConfig) {
+		rc.permissions = append(rc.permissions, permissions...)
+	}
+}
+
+//=============================================================================
+
+func WithCertificate(mode CertificateMode) RouteOption {
+	return func(rc *routeConfig) {
+		rc.certMode = &mode
+	}
+}
+
+//=============================================================================
+
+func WithApiKey(enabled bool) RouteOption {
+	return func(rc *routeConfig) {
+		rc.apiKey = &enabled
+	}
+}
+
+//=============================================================================
+
+func WithValidation(rules *core.TokenValidation) RouteOption {
+	return func(rc *routeConfig) {
+		rc.validations = append(rc.validations, rules)
+	}
+}
+
+//=============================================================================
+
+func WithRateLimit(limit *core.RateLimit) RouteOption {
+	return func(rc *routeConfig) {
+		rc.rateLimit = limit
+	}
+}
+
+//=============================================================================
+
+func WithAudit(enabled bool) RouteOption {
+	return func(rc *routeConfig) {
+		rc.audit = &enabled
+	}
+}
+
+//=============================================================================
+//--- Without this option the handler's context has no deadline
+
+func WithTimeout(timeout time.Duration) RouteOption {
+	return func(rc *routeConfig) {
+		rc.timeout = timeout
+	}
+}
+
+//=============================================================================
+
+func WithDelegation(policy *DelegationPolicy) RouteOption {
+	return func(rc *routeConfig) {
+		rc.delegation = policy
+	}
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (oc *OidcController) newRouteConfig(options []RouteOption) *routeConfig {
+	rc := &routeConfig{}
+
+	for _, opt := range options {
+		opt(rc)
+	}
+
+	return rc
+}
+
+//=============================================================================
+//--- Without permissions the route would be open to any authenticated user
+
+func (oc *OidcController) newPermissionConfig(permissions []Permission, options []RouteOption) *routeConfig {
+	core.ExitIfError(checkPermissions(permissions))
+
+	rc := oc.newRouteConfig(options)
+	rc.permissions = append(rc.permissions, permissions...)
+
+	return rc
+}
+
+//=============================================================================
+
+func checkPermissions(permissions []Permission) error {
+	if len(permissions) == 0 {
+		return errors.New("a permission-secured route requires at least one permission")
+	}
+
+	return nil
+}
+
+//=============================================================================
+//--- The controller's rules are read when the request is served
+
+func (oc *OidcController) validate(rc *routeConfig, us *UserSession) error {
+	if err := ValidateSession(us, oc.validation); err != nil {
+		return err
+	}
+
+	for _, rules := range rc.validations {
+		if err := ValidateSession(us, rules); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//=============================================================================
+//--- Routes without their own policy follow the controller's current one
+
+func (oc *OidcController) delegationPolicy(rc *routeConfig) *DelegationPolicy {
+	if rc.delegation != nil {
+		return rc.delegation
+	}
+
+	return oc.delegation
+}
+
+//=============================================================================
+
+func (oc *OidcController) certificateMode(rc *routeConfig) CertificateMode {
+	if rc.certMode != nil {
+		return *rc.certMode
+	}
+
+	return oc.certMode
+}
+
+//=============================================================================
+
+func (oc *OidcController) apiKeyEnabled(rc *routeConfig) bool {
+	if rc.apiKey != nil {
+		return *rc.apiKey
+	}
+
+	return oc.apiKeyOn
+}
+
+//=============================================================================
+
+func (oc *OidcController) auditEnabled(rc *routeConfig) bool {
+	if rc.audit != nil {
+		return *rc.audit
+	}
+
+	return oc.auditOn
+}
+
+//=============================================================================
+
+func (rc *routeConfig) isAuthorized(us *UserSession) bool {
+	if rc.roleCheck && ! us.IsUserInRole(rc.roles) {
+		return false
+	}
+
+	for _, p := range rc.permissions {
+		if ! us.Can(p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+//=============================================================================
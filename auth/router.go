@@ -0,0 +1,228 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"github.com/bit-fever/core/auth/role"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+//=============================================================================
+
+const OpenApiPath = "/openapi.json"
+
+//=============================================================================
+
+type Router struct {
+	sync.Mutex
+	oc      *OidcController
+	routes  gin.IRoutes
+	title   string
+	version string
+	infos   []*RouteInfo
+}
+
+//=============================================================================
+
+type RouteInfo struct {
+	Method      string
+	Path        string
+	Summary     string
+	Roles       []role.Role
+	Permissions []Permission
+	Input       reflect.Type
+	Output      reflect.Type
+	List        bool
+	lock        *sync.Mutex
+}
+
+//=============================================================================
+//===
+//=== Public functions
+//===
+//=============================================================================
+
+func NewRouter(oc *OidcController, routes gin.IRoutes, title string, version string) *Router {
+	return &Router{
+		oc     : oc,
+		routes : routes,
+		title  : title,
+		version: version,
+	}
+}
+
+//=============================================================================
+
+func (r *Router) Handle(method string, path string, h RestService, roles []role.Role, options ...RouteOption) *RouteInfo {
+	rc := r.oc.newRouteConfig(options)
+	rc.roles     = roles
+	rc.roleCheck = true
+
+	r.routes.Handle(method, path, r.oc.secure(h, rc))
+
+	return r.add(method, path, roles, rc.permissions)
+}
+
+//=============================================================================
+
+func (r *Router) HandleWith(method string, path string, h RestService, permissions []Permission, options ...RouteOption) *RouteInfo {
+	rc := r.oc.newPermissionConfig(permissions, options)
+
+	r.routes.Handle(method, path, r.oc.secure(h, rc))
+
+	return r.add(method, path, nil, rc.permissions)
+}
+
+//=============================================================================
+
+func Register[Req any, Resp any](r *Router, method string, path string, h TypedService[Req, Resp], roles []role.Role, options ...RouteOption) *RouteInfo {
+	ri := r.Handle(method, path, Typed(h), roles, options...)
+
+	ri.lock.Lock()
+	ri.Input  = reflect.TypeFor[Req]()
+	ri.Output = reflect.TypeFor[Resp]()
+	ri.lock.Unlock()
+
+	return ri
+}
+
+//=============================================================================
+//--- Returns a snapshot: later changes to the routes are not reflected, and
+//--- changes to the snapshot do not reach the router
+
+func (r *Router) Routes() []*RouteInfo {
+	r.Lock()
+	defer r.Unlock()
+
+	var list []*RouteInfo
+	for _, ri := range r.infos {
+		info := *ri
+		info.lock = &sync.Mutex{}
+		list = append(list, &info)
+	}
+
+	return list
+}
+
+//=============================================================================
+
+func (r *Router) ServeOpenApi() {
+	r.routes.GET(OpenApiPath, func(c *gin.Context) {
+		c.JSON(http.StatusOK, r.OpenApi())
+	})
+}
+
+//=============================================================================
+//===
+//=== RouteInfo methods
+//===
+//=============================================================================
+
+func (ri *RouteInfo) Describe(summary string) *RouteInfo {
+	ri.lock.Lock()
+	defer ri.lock.Unlock()
+
+	ri.Summary = summary
+	return ri
+}
+
+//=============================================================================
+
+func (ri *RouteInfo) Accepts(input any) *RouteInfo {
+	ri.lock.Lock()
+	defer ri.lock.Unlock()
+
+	ri.Input = reflect.TypeOf(input)
+	return ri
+}
+
+//=============================================================================
+
+func (ri *RouteInfo) Returns(output any) *RouteInfo {
+	ri.lock.Lock()
+	defer ri.lock.Unlock()
+
+	ri.Output = reflect.TypeOf(output)
+	ri.List   = false
+	return ri
+}
+
+//=============================================================================
+
+func (ri *RouteInfo) ReturnsList(item any) *RouteInfo {
+	ri.lock.Lock()
+	defer ri.lock.Unlock()
+
+	ri.Output = reflect.TypeOf(item)
+	ri.List   = true
+	return ri
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (r *Router) add(method string, relativePath string, roles []role.Role, permissions []Permission) *RouteInfo {
+	ri := &RouteInfo{
+		Method     : method,
+		Path       : r.absolutePath(relativePath),
+		Roles      : roles,
+		Permissions: permissions,
+		lock       : &r.Mutex,
+	}
+
+	r.Lock()
+	r.infos = append(r.infos, ri)
+	r.Unlock()
+
+	return ri
+}
+
+//=============================================================================
+
+func (r *Router) absolutePath(relativePath string) string {
+	group, ok := r.routes.(*gin.RouterGroup)
+	if !ok {
+		return relativePath
+	}
+
+	//--- Same rule as gin: the trailing slash of the relative path is kept
+
+	full := path.Join(group.BasePath(), relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(full, "/") {
+		full += "/"
+	}
+
+	return full
+}
+
+//=============================================================================
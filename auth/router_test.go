@@ -0,0 +1,213 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"encoding/json"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+type portfolio struct {
+	Id      uint       `json:"id"`
+	Name    string     `json:"name"`
+	Created time.Time  `json:"created"`
+	Parent  *portfolio `json:"parent,omitempty"`
+}
+
+//=============================================================================
+
+func TestRouterOpenApi(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key    := newTestKey(t)
+	oc     := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	engine := gin.New()
+	router := NewRouter(oc, engine, "Inventory", "1.0")
+
+	Register(router, "PUT", "/orders/:id", func(c *Context, in *updateOrderRequest) (*updateOrderResponse, error) {
+		return &updateOrderResponse{ Id: in.Id }, nil
+	}, roles.User).Describe("Update an order")
+
+	router.Handle("GET", "/portfolios", func(c *Context) {
+		_ = c.ReturnList([]portfolio{}, 0, 10, 0)
+	}, roles.Admin_User).ReturnsList(portfolio{})
+
+	router.HandleWith("DELETE", "/portfolios/:id", func(c *Context) {
+		c.Gin.Status(http.StatusNoContent)
+	}, []Permission{ "portfolio:delete" })
+
+	router.ServeOpenApi()
+
+	//--- Routes are secured as usual
+
+	if rr := serve(engine, "PUT", "/orders/3", "", nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d but got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	if rr := serve(engine, "GET", "/portfolios", mintToken(t, key, userClaims("bob", role.User)), nil); rr.Code != http.StatusOK {
+		t.Errorf("Expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	//--- The document is served at the well-known path
+
+	rr := serve(engine, "GET", OpenApiPath, "", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	var doc struct {
+		Info struct {
+			Title string `json:"title"`
+		} `json:"info"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid document: %v", err)
+	}
+
+	if doc.Info.Title != "Inventory" {
+		t.Errorf("Unexpected title: %s", doc.Info.Title)
+	}
+
+	for _, name := range []string{ "ListResponse", "ErrorResponse", "updateOrderResponse", "portfolio" } {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("Missing schema: %s", name)
+		}
+	}
+
+	update := doc.Paths["/orders/{id}"]["put"]
+	if update == nil {
+		t.Fatalf("Missing operation PUT /orders/{id}: %v", doc.Paths)
+	}
+
+	if update["summary"] != "Update an order" || len(update["x-roles"].([]any)) != 1 {
+		t.Errorf("Unexpected operation: %v", update)
+	}
+
+	params := update["parameters"].([]any)
+	if len(params) != 2 || params[0].(map[string]any)["in"] != "path" || params[1].(map[string]any)["name"] != "dryRun" {
+		t.Errorf("Unexpected parameters: %v", params)
+	}
+
+	body := update["requestBody"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)
+	if required := body["required"].([]any); len(required) != 1 || required[0] != "quantity" {
+		t.Errorf("Unexpected body schema: %v", body)
+	}
+
+	if del := doc.Paths["/portfolios/{id}"]["delete"]; del == nil || del["x-permissions"].([]any)[0] != "portfolio:delete" {
+		t.Errorf("Unexpected operation: %v", del)
+	}
+
+	if list := doc.Paths["/portfolios"]["get"]; list == nil || list["requestBody"] != nil {
+		t.Errorf("Unexpected operation: %v", list)
+	}
+
+	created := doc.Components.Schemas["portfolio"]["properties"].(map[string]any)["created"].(map[string]any)
+	if created["format"] != "date-time" {
+		t.Errorf("Unexpected time schema: %v", created)
+	}
+}
+
+//=============================================================================
+
+type Location struct {
+	Name string `json:"name"`
+}
+
+//-----------------------------------------------------------------------------
+
+type branch struct {
+	Office Location       `json:"office"`
+	Zone   *time.Location `json:"zone"`
+}
+
+//=============================================================================
+
+func TestRouterSchemaNames(t *testing.T) {
+	key    := newTestKey(t)
+	oc     := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	router := NewRouter(oc, gin.New(), "Inventory", "1.0")
+
+	ri := router.Handle("GET", "/branch", func(c *Context) {}, roles.User)
+
+	//--- Routes can be described while the document is being built
+
+	done := make(chan struct{})
+	go func() {
+		ri.Describe("Get the branch").Returns(branch{})
+		close(done)
+	}()
+	router.OpenApi()
+	<-done
+
+	schemas := router.OpenApi().Components.Schemas
+
+	for _, name := range []string{ "branch", "Location", "time.Location" } {
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("Missing schema: %s", name)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestRouterGroup(t *testing.T) {
+	key    := newTestKey(t)
+	oc     := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	engine := gin.New()
+	router := NewRouter(oc, engine.Group("/api/v1"), "Inventory", "1.0")
+
+	router.Handle("GET", "/portfolios/:id", func(c *Context) {}, roles.User)
+
+	routes := router.Routes()
+	if len(routes) != 1 || routes[0].Path != "/api/v1/portfolios/:id" {
+		t.Fatalf("Unexpected routes: %+v", routes)
+	}
+
+	if _, ok := router.OpenApi().Paths["/api/v1/portfolios/{id}"]; !ok {
+		t.Errorf("Missing the group prefix in the document")
+	}
+
+	//--- Snapshots can be changed without affecting the router
+
+	routes[0].Describe("Get a portfolio").Returns(portfolio{})
+
+	if ri := router.Routes()[0]; ri.Summary != "" || ri.Output != nil {
+		t.Errorf("The snapshot changed the router: %+v", ri)
+	}
+}
+
+//=============================================================================
@@ -25,6 +25,7 @@ THE SOFTWARE.
 package auth
 
 import (
+	"context"
 	"github.com/bit-fever/core/req"
 	"github.com/gin-gonic/gin"
 	"log/slog"
@@ -38,11 +39,14 @@ type RestService func(c *Context)
 //=============================================================================
 
 type Context struct {
-	Gin     *gin.Context
-	Session *UserSession
-	Log     *slog.Logger
-	Config  any
-	Token   string
+	Ctx        context.Context
+	Gin        *gin.Context
+	Session    *UserSession
+	Log        *slog.Logger
+	Config     any
+	Token      string
+	audit      *AuditRecord
+	revocation *RevocationRegistry
 }
 
 //=============================================================================
@@ -126,3 +130,37 @@ func (c *Context) GetCodeFromUrl() string {
 }
 
 //=============================================================================
+
+func (c *Context) CheckOwnership(entity Owned) error {
+	return DefaultOwnershipPolicy.Check(c.Session, entity)
+}
+
+//=============================================================================
+
+func (c *Context) CheckOwner(owner string) error {
+	return DefaultOwnershipPolicy.CheckOwner(c.Session, owner)
+}
+
+//=============================================================================
+
+func (c *Context) OwnerFilter() (all bool, owner string) {
+	return DefaultOwnershipPolicy.OwnerFilter(c.Session)
+}
+
+//=============================================================================
+
+func (c *Context) AuditBefore(entity any) {
+	if c.audit != nil {
+		c.audit.Before = snapshot(entity)
+	}
+}
+
+//=============================================================================
+
+func (c *Context) AuditAfter(entity any) {
+	if c.audit != nil {
+		c.audit.After = snapshot(entity)
+	}
+}
+
+//=============================================================================
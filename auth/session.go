@@ -26,21 +26,66 @@ package auth
 
 import (
 	"github.com/bit-fever/core/auth/role"
+	"maps"
+	"slices"
 	"time"
 )
 
 //=============================================================================
 
+const (
+	AuthMethodBearer      = "bearer"
+	AuthMethodCertificate = "certificate"
+	AuthMethodApiKey      = "apikey"
+)
+
+//=============================================================================
+
 type UserSession struct {
-	SessionID  string
-	Username   string
-	OnBehalfOf string
-	Name       string
-	Surname    string
-	Email      string
-	IssuedAt   time.Time
-	Expiry     time.Time
-	Roles      map[role.Role]any
+	SessionID   string
+	TokenID     string
+	Subject     string
+	Username    string
+	OnBehalfOf  string
+	Name        string
+	Surname     string
+	Email       string
+	Issuer      string
+	Realm       string
+	Audience    []string
+	ClientId    string
+	Scopes      []string
+	IssuedAt    time.Time
+	Expiry      time.Time
+	Roles       map[role.Role]any
+	Permissions map[Permission]any
+	AuthMethod  string
+	Certificate *CertificateIdentity
+	Attributes  Attributes
+}
+
+//=============================================================================
+//--- Deep copy: sessions are shared by the token cache, so handlers must not
+//--- be able to change the cached one
+
+func (us *UserSession) Clone() *UserSession {
+	clone := *us
+	clone.Audience    = slices.Clone(us.Audience)
+	clone.Scopes      = slices.Clone(us.Scopes)
+	clone.Roles       = maps.Clone(us.Roles)
+	clone.Permissions = maps.Clone(us.Permissions)
+	clone.Attributes  = maps.Clone(us.Attributes)
+
+	if us.Certificate != nil {
+		ci := *us.Certificate
+		ci.OrganizationalUnits = slices.Clone(ci.OrganizationalUnits)
+		ci.DNSNames            = slices.Clone(ci.DNSNames)
+		ci.EmailAddresses      = slices.Clone(ci.EmailAddresses)
+		ci.URIs                = slices.Clone(ci.URIs)
+		clone.Certificate = &ci
+	}
+
+	return &clone
 }
 
 //=============================================================================
@@ -63,3 +108,31 @@ func (us *UserSession) IsAdmin() bool {
 }
 
 //=============================================================================
+
+func (us *UserSession) Can(permission Permission) bool {
+	for p := range us.Permissions {
+		if permission.Matches(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//=============================================================================
+
+func (us *UserSession) IsDelegated() bool {
+	return us.OnBehalfOf != us.Username
+}
+
+//=============================================================================
+
+func (us *UserSession) Location() *time.Location {
+	if loc, ok := GetAttribute(us, LocationAttribute); ok && loc != nil {
+		return loc
+	}
+
+	return time.UTC
+}
+
+//=============================================================================
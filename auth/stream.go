@@ -0,0 +1,236 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/req"
+	"github.com/gin-gonic/gin"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+//=============================================================================
+
+const (
+	DefaultTicketTTL     = 30 * time.Second
+	TicketParam          = "ticket"
+	TicketProtocolPrefix = "ticket."
+)
+
+//=============================================================================
+
+type TicketResponse struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int    `json:"expiresIn"`
+}
+
+//=============================================================================
+
+type streamTicket struct {
+	session *UserSession
+	token   string
+}
+
+//=============================================================================
+
+type streamRegistry struct {
+	sync.Mutex
+	conns map[*streamConn]any
+}
+
+//-----------------------------------------------------------------------------
+
+type streamConn struct {
+	session *UserSession
+	cancel  context.CancelFunc
+}
+
+//=============================================================================
+//===
+//=== Public functions
+//===
+//=============================================================================
+
+func (oc *OidcController) SetTicketTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultTicketTTL
+	}
+
+	oc.ticketTTL = ttl
+}
+
+//=============================================================================
+//--- To be registered behind Secure: returns a single-use ticket for SecureStream
+
+func (oc *OidcController) TicketService() RestService {
+	return func(c *Context) {
+		ticket, err := newTicket()
+		if err != nil {
+			c.ReturnError(req.NewServerErrorByError(err))
+			return
+		}
+
+		expiry := time.Now().Add(oc.ticketTTL)
+		if !c.Session.Expiry.IsZero() && c.Session.Expiry.Before(expiry) {
+			expiry = c.Session.Expiry
+		}
+
+		oc.tickets.Put(hashToken(ticket), &streamTicket{
+			session: c.Session.Clone(),
+			token  : c.Token,
+		}, expiry)
+
+		_ = c.ReturnObject(&TicketResponse{
+			Ticket   : ticket,
+			ExpiresIn: int(math.Ceil(time.Until(expiry).Seconds())),
+		})
+	}
+}
+
+//=============================================================================
+//--- Like Secure, but authenticates with a ticket. The context is cancelled when
+//--- the token expires or the session is revoked: the handler must then return.
+//--- The session keeps the OnBehalfOf user it had when the ticket was issued
+
+func (oc *OidcController) SecureStream(h RestService, roles []role.Role, options ...RouteOption) func(c *gin.Context) {
+	rc := oc.newRouteConfig(options)
+	rc.roles     = roles
+	rc.roleCheck = true
+
+	return func(c *gin.Context) {
+		ticket := readTicket(c)
+		if ticket == "" {
+			req.ReturnUnauthorizedError(c, "Authorisation failed: a stream ticket is required")
+			return
+		}
+
+		st, ok := oc.tickets.Take(hashToken(ticket))
+		if !ok {
+			req.ReturnUnauthorizedError(c, "Authorisation failed: the stream ticket is invalid or expired")
+			return
+		}
+
+		oc.serve(c, rc, st.session.Clone(), st.token, h, true)
+	}
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func newStreamRegistry() *streamRegistry {
+	return &streamRegistry{
+		conns: map[*streamConn]any{},
+	}
+}
+
+//=============================================================================
+//--- Called for each registry the controller uses, so that its revocations
+//--- close the open streams
+
+func (sr *streamRegistry) watch(rr *RevocationRegistry) {
+	rr.AddListener(func(r *Revocation) {
+		sr.revoke(rr)
+	})
+}
+
+//=============================================================================
+
+func (sr *streamRegistry) add(conn *streamConn) {
+	sr.Lock()
+	sr.conns[conn] = nil
+	sr.Unlock()
+}
+
+//=============================================================================
+
+func (sr *streamRegistry) remove(conn *streamConn) {
+	sr.Lock()
+	delete(sr.conns, conn)
+	sr.Unlock()
+}
+
+//=============================================================================
+
+func (sr *streamRegistry) revoke(rr *RevocationRegistry) {
+	sr.Lock()
+	defer sr.Unlock()
+
+	for conn := range sr.conns {
+		if rr.IsRevoked(conn.session) {
+			conn.cancel()
+		}
+	}
+}
+
+//=============================================================================
+
+func newStreamContext(c *gin.Context, us *UserSession) (context.Context, context.CancelFunc) {
+	if us.Expiry.IsZero() {
+		return context.WithCancel(c.Request.Context())
+	}
+
+	return context.WithDeadline(c.Request.Context(), us.Expiry)
+}
+
+//=============================================================================
+//--- Browsers can only send the ticket in the URL (EventSource) or as one of the
+//--- offered WebSocket subprotocols
+
+func readTicket(c *gin.Context) string {
+	if ticket := c.Query(TicketParam); ticket != "" {
+		return ticket
+	}
+
+	for _, header := range c.Request.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			protocol = strings.TrimSpace(protocol)
+			if strings.HasPrefix(protocol, TicketProtocolPrefix) {
+				return strings.TrimPrefix(protocol, TicketProtocolPrefix)
+			}
+		}
+	}
+
+	return ""
+}
+
+//=============================================================================
+
+func newTicket() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,265 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+func newStreamRouter(oc *OidcController, started chan struct{}, done chan error) *gin.Engine {
+	router := gin.New()
+	router.POST("/stream/ticket", oc.Secure(oc.TicketService(), roles.Admin_User_Service))
+	router.GET ("/stream",        oc.SecureStream(func(c *Context) {
+		c.Gin.Status(http.StatusOK)
+		started <- struct{}{}
+		<-c.Ctx.Done()
+		done <- c.Ctx.Err()
+	}, roles.User))
+
+	return router
+}
+
+//=============================================================================
+
+func getTicket(t *testing.T, router *gin.Engine, token string) string {
+	rr := serve(router, "POST", "/stream/ticket", token, nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	var tr TicketResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &tr); err != nil || tr.Ticket == "" || tr.ExpiresIn <= 0 {
+		t.Fatalf("Invalid ticket response: %s", rr.Body.String())
+	}
+
+	return tr.Ticket
+}
+
+//=============================================================================
+
+func waitForStart(t *testing.T, started chan struct{}) {
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("The stream was not started")
+	}
+}
+
+//=============================================================================
+
+func waitForStream(t *testing.T, done chan error) error {
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(3 * time.Second):
+		t.Fatalf("The stream was not closed")
+		return nil
+	}
+}
+
+//=============================================================================
+
+func TestSecureStreamRevocation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key    := newTestKey(t)
+	oc     := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	start  := make(chan struct{}, 1)
+	done   := make(chan error, 1)
+	router := newStreamRouter(oc, start, done)
+
+	claims := userClaims("alice", role.User)
+	claims["sid"] = "session-1"
+	token := mintToken(t, key, claims)
+
+	if rr := serve(router, "GET", "/stream", "", nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d but got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	ticket := getTicket(t, router, token)
+	go serve(router, "GET", "/stream?ticket="+ ticket, "", nil)
+
+	//--- Wait for the stream to start, then revoke the session
+
+	waitForStart(t, start)
+	if err := oc.Revocations().RevokeSession("session-1"); err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+
+	if err := waitForStream(t, done); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the stream to be cancelled but got %v", err)
+	}
+
+	//--- Tickets are single-use
+
+	if rr := serve(router, "GET", "/stream?ticket="+ ticket, "", nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d but got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
+
+//=============================================================================
+
+func TestSecureStreamReplacedRegistry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key    := newTestKey(t)
+	oc     := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	start  := make(chan struct{}, 1)
+	done   := make(chan error, 1)
+	router := newStreamRouter(oc, start, done)
+
+	open := func(sid string) {
+		claims := userClaims("alice", role.User)
+		claims["sid"] = sid
+		go serve(router, "GET", "/stream?ticket="+ getTicket(t, router, mintToken(t, key, claims)), "", nil)
+		waitForStart(t, start)
+	}
+
+	//--- A stream is served with the default registry, then the registry is replaced
+
+	open("session-1")
+	_ = oc.Revocations().RevokeSession("session-1")
+	if err := waitForStream(t, done); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the stream to be cancelled but got %v", err)
+	}
+
+	oc.SetRevocationRegistry(NewRevocationRegistry(time.Hour))
+
+	open("session-2")
+	if err := oc.Revocations().RevokeSession("session-2"); err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+
+	if err := waitForStream(t, done); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the stream to be cancelled but got %v", err)
+	}
+}
+
+//=============================================================================
+
+func TestSecureStreamExpiry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key    := newTestKey(t)
+	oc     := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	start  := make(chan struct{}, 1)
+	done   := make(chan error, 1)
+	router := newStreamRouter(oc, start, done)
+
+	claims := userClaims("alice", role.User)
+	claims["exp"] = time.Now().Add(2 * time.Second).Unix()
+
+	ticket := getTicket(t, router, mintToken(t, key, claims))
+	go serve(router, "GET", "/stream", "", map[string]string{ "Sec-WebSocket-Protocol": "bf.events, "+ TicketProtocolPrefix + ticket })
+
+	if err := waitForStream(t, done); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the stream to expire but got %v", err)
+	}
+}
+
+//=============================================================================
+
+func TestSecureStreamRoles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key    := newTestKey(t)
+	oc     := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	router := newStreamRouter(oc, make(chan struct{}, 1), make(chan error, 1))
+
+	ticket := getTicket(t, router, mintToken(t, key, userClaims("inventory", role.Service)))
+
+	if rr := serve(router, "GET", "/stream?ticket="+ ticket, "", nil); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d but got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+//=============================================================================
+
+func TestSecureStreamPipeline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key  := newTestKey(t)
+	oc   := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	sink := &memoryAuditSink{}
+	ar   := NewAuditRecorder(sink)
+	oc.SetAuditRecorder(ar, false)
+
+	router := gin.New()
+	router.POST("/stream/ticket", oc.Secure(oc.TicketService(), roles.Admin_User_Service))
+	router.GET ("/stream",        oc.SecureStream(func(c *Context) {
+		c.Gin.Status(http.StatusOK)
+	}, roles.User,
+		WithValidation(&core.TokenValidation{ Scopes: []string{ "stream" } }),
+		WithRateLimit(&core.RateLimit{ Requests: 1, Period: time.Hour }),
+		WithAudit(true)))
+
+	claims := func(username string, scope string) map[string]any {
+		c := userClaims(username, role.User)
+		c["scope"] = scope
+		return c
+	}
+
+	alice := mintToken(t, key, claims("alice", "stream"))
+	bob   := mintToken(t, key, claims("bob",   "orders"))
+
+	cases := []struct {
+		token  string
+		status int
+	}{
+		{ alice, http.StatusOK              },
+		{ alice, http.StatusTooManyRequests },
+		{ bob,   http.StatusForbidden       },
+	}
+
+	for i, tc := range cases {
+		ticket := getTicket(t, router, tc.token)
+		if rr := serve(router, "GET", "/stream?ticket="+ ticket, "", nil); rr.Code != tc.status {
+			t.Errorf("Case %d: expected status %d but got %d", i, tc.status, rr.Code)
+		}
+	}
+
+	ar.Close()
+
+	if len(sink.records) != len(cases) {
+		t.Fatalf("Expected %d audit records but got %d", len(cases), len(sink.records))
+	}
+
+	if r := sink.records[0]; r.Username != "alice" || r.Route != "/stream" || r.Status != http.StatusOK {
+		t.Errorf("Unexpected audit record: %+v", r)
+	}
+}
+
+//=============================================================================
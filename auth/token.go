@@ -25,42 +25,82 @@ THE SOFTWARE.
 package auth
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"github.com/bit-fever/core"
 	"github.com/bit-fever/core/req"
 	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-jose/go-jose/v4"
 	"log/slog"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 )
 
 //=============================================================================
 
+const (
+	DefaultProfile    = "default"
+	DefaultHttpClient = "bf"
+)
+
+//=============================================================================
+
+const (
+	maxExpirySkew = 5 * time.Second
+	retryDelay    = 5 * time.Second
+)
+
+//=============================================================================
+
 type TokenResponse struct {
-	AccessToken  string `json:"access_token"`
-	TokenType    string `json:"token_type"`
-	ExpiresIn    int    `json:"expires_in"`
-	RefreshToken string `json:"refresh_token"`
-	Scope        string `json:"scope"`
+	AccessToken     string `json:"access_token"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int    `json:"expires_in"`
+	RefreshToken    string `json:"refresh_token"`
+	Scope           string `json:"scope"`
+	IssuedTokenType string `json:"issued_token_type"`
 }
 
 //=============================================================================
 
 type RestContext struct {
 	sync.RWMutex
+	name          string
 	clientId      string
 	clientSecret  string
+	scopes        []string
 	client        *http.Client
-	provider      *oidc.Provider
+	tokenUrl      string
+	authMethod    string
+	signer        jose.Signer
+	audience      string
+	impersonation *core.Impersonation
 	tokenResponse * TokenResponse
-	tokenDate     time.Time
+	validUntil    time.Time
+	refreshing    *refreshCall
+	timer         *time.Timer
+	stopped       bool
 }
 
 //=============================================================================
 
-var restContext *RestContext
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+//=============================================================================
+
+var profiles = struct {
+	sync.RWMutex
+	contexts map[string]*RestContext
+}{
+	contexts: map[string]*RestContext{},
+}
 
 //=============================================================================
 //===
@@ -69,37 +109,147 @@ var restContext *RestContext
 //=============================================================================
 
 func InitAuthentication(auth *core.Authentication) {
-	client        := req.GetClient("bf")
-	ccontext      := oidc.ClientContext(context.Background(), client)
-	provider, err := oidc.NewProvider(ccontext, auth.Authority)
+	core.ExitIfError(checkProfileNames(auth.Profiles))
+
+	AddTokenProfile(&core.TokenProfile{
+		Name         : DefaultProfile,
+		Authority    : auth.Authority,
+		ClientId     : auth.ClientId,
+		ClientSecret : auth.ClientSecret,
+		AuthMethod   : auth.AuthMethod,
+		PrivateKey   : auth.PrivateKey,
+		KeyId        : auth.KeyId,
+		Scopes       : auth.Scopes,
+		Audience     : auth.Audience,
+		Impersonation: auth.Impersonation,
+	})
+
+	for i := range auth.Profiles {
+		p := auth.Profiles[i]
+		if p.Authority == "" {
+			p.Authority = auth.Authority
+		}
+
+		AddTokenProfile(&p)
+	}
+}
+
+//=============================================================================
+
+func AddTokenProfile(profile *core.TokenProfile) {
+	rc, err := NewRestContext(profile)
 	core.ExitIfError(err)
 
-	restContext = &RestContext{
-		clientId    : auth.ClientId,
-		clientSecret: auth.ClientSecret,
-		client      : client,
-		provider    : provider,
+	profiles.Lock()
+	old := profiles.contexts[rc.name]
+	profiles.contexts[rc.name] = rc
+	profiles.Unlock()
+
+	//--- The replaced context must not keep refreshing in the background
+
+	if old != nil {
+		old.Stop()
+	}
+}
+
+//=============================================================================
+
+func NewRestContext(profile *core.TokenProfile) (*RestContext, error) {
+	if profile.Name == "" {
+		return nil, errors.New("token profile without a name")
+	}
+
+	clientId := profile.HttpClient
+	if clientId == "" {
+		clientId = DefaultHttpClient
 	}
+
+	client := req.GetClient(clientId)
+	if client == nil {
+		return nil, errors.New("token profile '"+ profile.Name +"': unknown HTTP client: "+ clientId)
+	}
+
+	authMethod := profile.AuthMethod
+	if authMethod == "" {
+		authMethod = AuthMethodClientSecretPost
+	}
+
+	signer, err := newClientSigner(profile.Name, authMethod, profile.PrivateKey, profile.KeyId)
+	if err != nil {
+		return nil, err
+	}
+
+	ccontext      := oidc.ClientContext(context.Background(), client)
+	provider, err := oidc.NewProvider(ccontext, profile.Authority)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RestContext{
+		name         : profile.Name,
+		clientId     : profile.ClientId,
+		clientSecret : profile.ClientSecret,
+		scopes       : profile.Scopes,
+		client       : client,
+		tokenUrl     : provider.Endpoint().TokenURL,
+		authMethod   : authMethod,
+		signer       : signer,
+		audience     : profile.Audience,
+		impersonation: profile.Impersonation,
+	}, nil
 }
 
 //=============================================================================
 
 func Token() (string, error) {
-	restContext.Lock()
-	defer restContext.Unlock()
-
-	if restContext.tokenResponse == nil || isTokenExpired() {
-		t,err := getToken()
-		if err != nil {
-			slog.Error("Cannot get authentication token", "error", err)
-			return "", err
-		}
+	return TokenFor(DefaultProfile)
+}
 
-		restContext.tokenResponse = t
-		restContext.tokenDate     = time.Now()
+//=============================================================================
+
+func TokenFor(name string) (string, error) {
+	rc, err := getProfile(name)
+	if err != nil {
+		return "", err
+	}
+
+	return rc.Token()
+}
+
+//=============================================================================
+
+func (rc *RestContext) Token() (string, error) {
+	rc.RLock()
+	if rc.tokenResponse != nil && time.Now().Before(rc.validUntil) {
+		token := rc.tokenResponse.AccessToken
+		rc.RUnlock()
+		return token, nil
 	}
+	rc.RUnlock()
 
-	return restContext.tokenResponse.AccessToken, nil
+	call := rc.refresh()
+	<-call.done
+
+	if call.err != nil {
+		return "", call.err
+	}
+
+	rc.RLock()
+	defer rc.RUnlock()
+
+	return rc.tokenResponse.AccessToken, nil
+}
+
+//=============================================================================
+
+func (rc *RestContext) Stop() {
+	rc.Lock()
+	defer rc.Unlock()
+
+	rc.stopped = true
+	if rc.timer != nil {
+		rc.timer.Stop()
+	}
 }
 
 //=============================================================================
@@ -107,39 +257,169 @@ func Token() (string, error) {
 //=== Private functions
 //===
 //=============================================================================
+//--- Starts a refresh, or joins the one in flight. Callers keep getting the
+//--- current token (if still valid) while the refresh runs
+
+func (rc *RestContext) refresh() *refreshCall {
+	rc.Lock()
+	defer rc.Unlock()
+
+	if rc.refreshing != nil {
+		return rc.refreshing
+	}
+
+	refreshToken := ""
+	if rc.tokenResponse != nil {
+		refreshToken = rc.tokenResponse.RefreshToken
+	}
+
+	call := &refreshCall{ done: make(chan struct{}) }
+	rc.refreshing = call
+
+	go func() {
+		t, err := rc.fetchToken(refreshToken)
 
-func getToken() (*TokenResponse, error) {
-	params := "grant_type=client_credentials&client_id="+restContext.clientId+"&client_secret="+ restContext.clientSecret
-	resp   := TokenResponse{}
-	url    := restContext.provider.Endpoint().TokenURL
+		rc.Lock()
+		if err == nil {
+			rc.setToken(t)
+		} else {
+			slog.Error("Cannot get authentication token", "profile", rc.name, "error", err)
+			rc.scheduleRetry()
+		}
+		call.err      = err
+		rc.refreshing = nil
+		rc.Unlock()
+
+		close(call.done)
+	}()
+
+	return call
+}
+
+//=============================================================================
+
+func (rc *RestContext) fetchToken(refreshToken string) (*TokenResponse, error) {
+	if refreshToken != "" {
+		t, err := rc.refreshGrant(refreshToken)
+		if err == nil {
+			return t, nil
+		}
 
-	body := []byte(params)
-	reader := bytes.NewReader(body)
+		slog.Warn("Cannot refresh the token, requesting a new one", "profile", rc.name, "error", err)
+	}
+
+	return rc.getToken()
+}
+
+//=============================================================================
+
+func (rc *RestContext) setToken(t *TokenResponse) {
+	now      := time.Now()
+	lifetime := time.Duration(t.ExpiresIn) * time.Second
+	skew     := min(maxExpirySkew, lifetime / 10)
+
+	rc.tokenResponse = t
+	rc.validUntil    = now.Add(lifetime - skew)
+
+	if lifetime > 0 {
+		//--- Refresh between 75% and 85% of the lifetime, to spread the load
+
+		jitter := 0.75 + rand.Float64() * 0.1
+		rc.schedule(time.Duration(float64(lifetime) * jitter))
+	}
+}
+
+//=============================================================================
+
+func (rc *RestContext) scheduleRetry() {
+	if remaining := time.Until(rc.validUntil); remaining > 0 {
+		rc.schedule(min(retryDelay, remaining / 2))
+	}
+}
 
-	rq, err := http.NewRequest("POST", url, reader)
+//=============================================================================
+
+func (rc *RestContext) schedule(delay time.Duration) {
+	if rc.stopped {
+		return
+	}
+
+	if rc.timer != nil {
+		rc.timer.Stop()
+	}
+
+	rc.timer = time.AfterFunc(delay, func() {
+		rc.refresh()
+	})
+}
+
+//=============================================================================
+
+func (rc *RestContext) getToken() (*TokenResponse, error) {
+	params := url.Values{}
+	params.Set("grant_type", "client_credentials")
+
+	if len(rc.scopes) > 0 {
+		params.Set("scope", strings.Join(rc.scopes, " "))
+	}
+
+	if rc.audience != "" {
+		params.Set("audience", rc.audience)
+	}
+
+	return rc.postTokenRequest(params)
+}
+
+//=============================================================================
+
+func (rc *RestContext) refreshGrant(refreshToken string) (*TokenResponse, error) {
+	params := url.Values{}
+	params.Set("grant_type",    "refresh_token")
+	params.Set("refresh_token", refreshToken)
+
+	return rc.postTokenRequest(params)
+}
+
+//=============================================================================
+
+func (rc *RestContext) postTokenRequest(params url.Values) (*TokenResponse, error) {
+	if err := rc.authenticate(params); err != nil {
+		return nil, err
+	}
+
+	rq, err := http.NewRequest("POST", rc.tokenUrl, strings.NewReader(params.Encode()))
 	if err != nil {
 		slog.Error("Error creating a POST request", "error", err.Error())
 		return nil, err
 	}
 
+	rc.setBasicAuth(rq)
 	rq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rq.Header.Set("Accept",       req.ApplicationJson)
 
-	res, err := restContext.client.Do(rq)
-	err = req.BuildResponse(res, err, &resp)
+	res, err := rc.client.Do(rq)
+	if err != nil {
+		slog.Error("Error sending request", "error", err.Error())
+		return nil, err
+	}
 
-	return &resp, err
+	return readTokenResponse(res)
 }
 
 //=============================================================================
+//--- Profile names must be unique and cannot be the reserved DefaultProfile
 
-func isTokenExpired() bool {
-	date := restContext.tokenDate
-	now  := time.Now()
+func checkProfileNames(list []core.TokenProfile) error {
+	names := map[string]bool{ DefaultProfile: true }
 
-	curDur := now.Sub(date)
-	maxDur := time.Duration(restContext.tokenResponse.ExpiresIn * 9/10) * time.Second
+	for _, p := range list {
+		if names[p.Name] {
+			return errors.New("token profile name is reserved or duplicated: "+ p.Name)
+		}
+		names[p.Name] = true
+	}
 
-	return curDur >= maxDur
+	return nil
 }
 
 //=============================================================================
@@ -0,0 +1,507 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/req"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+type tokenServer struct {
+	*httptest.Server
+	sync.Mutex
+	calls atomic.Int32
+	forms []map[string]string
+}
+
+//=============================================================================
+
+func newTokenServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) *tokenServer {
+	ts := &tokenServer{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", req.ApplicationJson)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer"                : ts.URL,
+			"token_endpoint"        : ts.URL +"/token",
+			"authorization_endpoint": ts.URL +"/auth",
+			"jwks_uri"              : ts.URL +"/certs",
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		ts.calls.Add(1)
+		_ = r.ParseForm()
+
+		form := map[string]string{}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		if id, secret, ok := r.BasicAuth(); ok {
+			form["basic_id"]     = id
+			form["basic_secret"] = secret
+		}
+		ts.Lock()
+		ts.forms = append(ts.forms, form)
+		ts.Unlock()
+
+		handler(w, r)
+	})
+
+	ts.Server = httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	req.SetClient(DefaultHttpClient, ts.Client())
+
+	return ts
+}
+
+//=============================================================================
+
+func (ts *tokenServer) form(i int) map[string]string {
+	ts.Lock()
+	defer ts.Unlock()
+
+	if i < 0 {
+		i += len(ts.forms)
+	}
+
+	return ts.forms[i]
+}
+
+//=============================================================================
+
+func writeToken(w http.ResponseWriter, token string, expiresIn int) {
+	writeTokenResponse(w, &TokenResponse{
+		AccessToken: token,
+		TokenType  : "Bearer",
+		ExpiresIn  : expiresIn,
+	})
+}
+
+//=============================================================================
+
+func writeTokenResponse(w http.ResponseWriter, tr *TokenResponse) {
+	w.Header().Set("Content-Type", req.ApplicationJson)
+	_ = json.NewEncoder(w).Encode(tr)
+}
+
+//=============================================================================
+
+func setTokenProfile(t *testing.T, rc *RestContext) {
+	profiles.Lock()
+	old, found := profiles.contexts[rc.name]
+	profiles.contexts[rc.name] = rc
+	profiles.Unlock()
+
+	t.Cleanup(func() {
+		rc.Stop()
+
+		profiles.Lock()
+		defer profiles.Unlock()
+
+		if found {
+			profiles.contexts[rc.name] = old
+		} else {
+			delete(profiles.contexts, rc.name)
+		}
+	})
+}
+
+//=============================================================================
+
+func TestTokenProfiles(t *testing.T) {
+	ts := newTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeToken(w, "token-"+ r.PostFormValue("client_id"), 300)
+	})
+
+	for _, p := range []*core.TokenProfile{
+		{ Name: DefaultProfile, Authority: ts.URL, ClientId: "bf-service", ClientSecret: "s1" },
+		{ Name: "broker",       Authority: ts.URL, ClientId: "bf-broker",  ClientSecret: "s2", Scopes: []string{ "orders", "positions" }},
+	} {
+		rc, err := NewRestContext(p)
+		if err != nil {
+			t.Fatalf("NewRestContext failed: %v", err)
+		}
+		setTokenProfile(t, rc)
+	}
+
+	for i := 0; i < 2; i++ {
+		if token, err := Token(); err != nil || token != "token-bf-service" {
+			t.Errorf("Unexpected default token: %s (%v)", token, err)
+		}
+
+		if token, err := TokenFor("broker"); err != nil || token != "token-bf-broker" {
+			t.Errorf("Unexpected broker token: %s (%v)", token, err)
+		}
+	}
+
+	if calls := ts.calls.Load(); calls != 2 {
+		t.Errorf("Expected tokens to be cached, but got %d calls", calls)
+	}
+
+	if scope := ts.form(1)["scope"]; scope != "orders positions" {
+		t.Errorf("Unexpected scope: %s", scope)
+	}
+
+	if _, err := TokenFor("missing"); err == nil {
+		t.Errorf("Expected an error for an unknown profile")
+	}
+
+	if _, err := NewRestContext(&core.TokenProfile{ Name: "x", Authority: ts.URL, HttpClient: "missing" }); err == nil {
+		t.Errorf("Expected an error for an unknown HTTP client")
+	}
+
+	for i, names := range [][]string{ { DefaultProfile }, { "broker", "broker" } } {
+		var list []core.TokenProfile
+		for _, name := range names {
+			list = append(list, core.TokenProfile{ Name: name })
+		}
+
+		if err := checkProfileNames(list); err == nil {
+			t.Errorf("Case %d: expected an error for the profile names %v", i, names)
+		}
+	}
+
+	if err := checkProfileNames([]core.TokenProfile{{ Name: "broker" }, { Name: "feed" }}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+//=============================================================================
+
+func TestTokenSingleFlight(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	ts := newTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		writeToken(w, "shared", 300)
+	})
+
+	rc, err := NewRestContext(&core.TokenProfile{ Name: "single", Authority: ts.URL, ClientId: "bf-service" })
+	if err != nil {
+		t.Fatalf("NewRestContext failed: %v", err)
+	}
+	setTokenProfile(t, rc)
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 10)
+
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i], _ = rc.Token()
+		}()
+	}
+
+	//--- Callers arriving before the release join the call in flight, the others
+	//--- get the cached token
+
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("The token was not requested")
+	}
+
+	close(release)
+	wg.Wait()
+
+	if calls := ts.calls.Load(); calls != 1 {
+		t.Errorf("Expected concurrent requests to share one call, but got %d", calls)
+	}
+
+	for _, token := range tokens {
+		if token != "shared" {
+			t.Errorf("Unexpected token: %s", token)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestTokenBackgroundRefresh(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	ts := newTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.PostForm.Get("grant_type") {
+		case "client_credentials":
+			writeTokenResponse(w, &TokenResponse{ AccessToken: "first", ExpiresIn: 1, RefreshToken: "r1" })
+		case "refresh_token":
+			started <- struct{}{}
+			<-release
+			writeTokenResponse(w, &TokenResponse{ AccessToken: "second", ExpiresIn: 300 })
+		}
+	})
+
+	rc, err := NewRestContext(&core.TokenProfile{ Name: "refresh", Authority: ts.URL, ClientId: "bf-service" })
+	if err != nil {
+		t.Fatalf("NewRestContext failed: %v", err)
+	}
+	setTokenProfile(t, rc)
+
+	if token, err := rc.Token(); err != nil || token != "first" {
+		t.Fatalf("Unexpected token: %s (%v)", token, err)
+	}
+
+	//--- The refresh starts before expiry and the valid token is still served
+
+	select {
+	case <-started:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("The token was not refreshed")
+	}
+
+	if token, err := rc.Token(); err != nil || token != "first" {
+		t.Errorf("Expected the current token during the refresh: %s (%v)", token, err)
+	}
+
+	rc.RLock()
+	call := rc.refreshing
+	rc.RUnlock()
+
+	close(release)
+	<-call.done
+
+	if token, _ := rc.Token(); token != "second" {
+		t.Errorf("Expected the refreshed token but got %s", token)
+	}
+
+	form := ts.form(1)
+	if form["grant_type"] != "refresh_token" || form["refresh_token"] != "r1" {
+		t.Errorf("Unexpected refresh request: %v", form)
+	}
+
+	if calls := ts.calls.Load(); calls != 2 {
+		t.Errorf("Expected 2 calls but got %d", calls)
+	}
+}
+
+//=============================================================================
+
+func TestAddTokenProfileStopsReplaced(t *testing.T) {
+	ts := newTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		writeToken(w, "token", 300)
+	})
+
+	profile := &core.TokenProfile{ Name: "replaced", Authority: ts.URL, ClientId: "bf-service" }
+	AddTokenProfile(profile)
+
+	profiles.RLock()
+	first := profiles.contexts[profile.Name]
+	profiles.RUnlock()
+
+	AddTokenProfile(profile)
+
+	profiles.Lock()
+	second := profiles.contexts[profile.Name]
+	delete(profiles.contexts, profile.Name)
+	profiles.Unlock()
+
+	second.Stop()
+
+	first.RLock()
+	defer first.RUnlock()
+
+	if first == second || !first.stopped {
+		t.Errorf("Expected the replaced profile to be stopped")
+	}
+}
+
+//=============================================================================
+
+func TestTokenRefreshFallback(t *testing.T) {
+	ts := newTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.PostForm.Get("grant_type") == "refresh_token" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		writeTokenResponse(w, &TokenResponse{ AccessToken: "token", ExpiresIn: 300, RefreshToken: "expired" })
+	})
+
+	rc, err := NewRestContext(&core.TokenProfile{ Name: "fallback", Authority: ts.URL, ClientId: "bf-service" })
+	if err != nil {
+		t.Fatalf("NewRestContext failed: %v", err)
+	}
+	setTokenProfile(t, rc)
+
+	if _, err := rc.Token(); err != nil {
+		t.Fatalf("Token failed: %v", err)
+	}
+
+	//--- Forces the token to expire
+
+	rc.Lock()
+	rc.validUntil = time.Now()
+	rc.Unlock()
+
+	if token, err := rc.Token(); err != nil || token != "token" {
+		t.Fatalf("Unexpected token: %s (%v)", token, err)
+	}
+
+	grants := []string{ ts.form(0)["grant_type"], ts.form(1)["grant_type"], ts.form(2)["grant_type"] }
+	if grants[0] != "client_credentials" || grants[1] != "refresh_token" || grants[2] != "client_credentials" {
+		t.Errorf("Unexpected grants: %v", grants)
+	}
+}
+
+//=============================================================================
+
+func TestTokenClientAuthentication(t *testing.T) {
+	ts := newTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.PostForm.Get("client_id") == "rejected" {
+			w.Header().Set("Content-Type", req.ApplicationJson)
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+			return
+		}
+
+		writeToken(w, "token", 300)
+	})
+
+	//--- Private key for private_key_jwt
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Cannot generate key: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Cannot encode key: %v", err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "client.pem")
+	if err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{ Type: "PRIVATE KEY", Bytes: der }), 0600); err != nil {
+		t.Fatalf("Cannot write key: %v", err)
+	}
+
+	secret := "a&b+c=d"
+	for i, p := range []*core.TokenProfile{
+		{ Name: "basic", ClientId: "bf-service", ClientSecret: secret, AuthMethod: AuthMethodClientSecretBasic, Scopes: []string{ "orders" }, Audience: "bf-inventory" },
+		{ Name: "post",  ClientId: "bf-service", ClientSecret: secret },
+		{ Name: "jwt",   ClientId: "bf-service", AuthMethod: AuthMethodPrivateKeyJwt, PrivateKey: keyFile, KeyId: "k1" },
+	} {
+		p.Authority = ts.URL
+
+		rc, err := NewRestContext(p)
+		if err != nil {
+			t.Fatalf("NewRestContext failed: %v", err)
+		}
+		setTokenProfile(t, rc)
+
+		if token, err := rc.Token(); err != nil || token != "token" {
+			t.Fatalf("%s: unexpected token: %s (%v)", p.Name, token, err)
+		}
+
+		form := ts.form(i)
+		switch p.Name {
+		case "basic":
+			id, _  := url.QueryUnescape(form["basic_id"])
+			sec, _ := url.QueryUnescape(form["basic_secret"])
+			if id != "bf-service" || sec != secret || form["client_secret"] != "" {
+				t.Errorf("Unexpected basic credentials: %v", form)
+			}
+			if form["scope"] != "orders" || form["audience"] != "bf-inventory" {
+				t.Errorf("Unexpected scope or audience: %v", form)
+			}
+
+		case "post":
+			if form["client_id"] != "bf-service" || form["client_secret"] != secret || form["basic_id"] != "" {
+				t.Errorf("Unexpected post credentials: %v", form)
+			}
+
+		case "jwt":
+			if form["client_assertion_type"] != ClientAssertionType || form["client_secret"] != "" {
+				t.Errorf("Unexpected assertion request: %v", form)
+			}
+
+			assertion, err := jwt.ParseSigned(form["client_assertion"], []jose.SignatureAlgorithm{ jose.ES256 })
+			if err != nil {
+				t.Fatalf("Invalid assertion: %v", err)
+			}
+
+			var claims jwt.Claims
+			if err = assertion.Claims(&key.PublicKey, &claims); err != nil {
+				t.Fatalf("Invalid assertion signature: %v", err)
+			}
+
+			if claims.Subject != "bf-service" || !claims.Audience.Contains(ts.URL +"/token") || assertion.Headers[0].KeyID != "k1" {
+				t.Errorf("Unexpected assertion: %+v", claims)
+			}
+		}
+	}
+
+	//--- Error responses are typed
+
+	rc, err := NewRestContext(&core.TokenProfile{ Name: "rejected", Authority: ts.URL, ClientId: "rejected", AuthMethod: AuthMethodClientSecretPost })
+	if err != nil {
+		t.Fatalf("NewRestContext failed: %v", err)
+	}
+	setTokenProfile(t, rc)
+
+	var te *TokenError
+	if _, err = rc.Token(); !errors.As(err, &te) || te.Code != "invalid_client" || te.Description != "bad credentials" || te.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a typed token error but got %v", err)
+	}
+
+	//--- Invalid configurations
+
+	for _, p := range []*core.TokenProfile{
+		{ Name: "unknown", Authority: ts.URL, AuthMethod: "client_secret_jwt" },
+		{ Name: "nokey",   Authority: ts.URL, AuthMethod: AuthMethodPrivateKeyJwt },
+	} {
+		if _, err := NewRestContext(p); err == nil {
+			t.Errorf("%s: expected a configuration error", p.Name)
+		}
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,101 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/req"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+//=============================================================================
+
+const (
+	ReasonInvalidIssuer     = "invalid_issuer"
+	ReasonInvalidAudience   = "invalid_audience"
+	ReasonClientNotAllowed  = "client_not_allowed"
+	ReasonInsufficientScope = "insufficient_scope"
+	ReasonMaxAgeExceeded    = "max_age_exceeded"
+)
+
+//=============================================================================
+//--- Issuer and audience only apply to tokens. API key and certificate sessions
+//--- have no client or scopes (and API keys no issue time), so routes requiring
+//--- them reject such sessions
+
+func ValidateSession(us *UserSession, rules *core.TokenValidation) error {
+	if rules == nil {
+		return nil
+	}
+
+	if us.AuthMethod == AuthMethodBearer {
+		if len(rules.Issuers) > 0 && !slices.Contains(rules.Issuers, us.Issuer) {
+			return req.NewErrorWithReason(http.StatusUnauthorized, ReasonInvalidIssuer, "Token issuer not accepted: %v", us.Issuer)
+		}
+
+		if len(rules.Audience) > 0 && !containsAny(us.Audience, rules.Audience) {
+			return req.NewErrorWithReason(http.StatusUnauthorized, ReasonInvalidAudience, "Token audience not accepted: %v", strings.Join(us.Audience, ","))
+		}
+	}
+
+	if len(rules.Clients) > 0 && !slices.Contains(rules.Clients, us.ClientId) {
+		return req.NewErrorWithReason(http.StatusForbidden, ReasonClientNotAllowed, "Client not allowed to access this API: %v", us.ClientId)
+	}
+
+	for _, scope := range rules.Scopes {
+		if !slices.Contains(us.Scopes, scope) {
+			return req.NewErrorWithReason(http.StatusForbidden, ReasonInsufficientScope, "Missing required scope: %v", scope)
+		}
+	}
+
+	if rules.MaxAge > 0 {
+		if us.IssuedAt.IsZero() || time.Since(us.IssuedAt) > rules.MaxAge {
+			return req.NewErrorWithReason(http.StatusUnauthorized, ReasonMaxAgeExceeded, "Token is older than %v, a new authentication is required", rules.MaxAge)
+		}
+	}
+
+	return nil
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func containsAny(values []string, accepted []string) bool {
+	for _, v := range values {
+		if slices.Contains(accepted, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//=============================================================================
@@ -0,0 +1,180 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"encoding/json"
+	"github.com/bit-fever/core"
+	"github.com/bit-fever/core/auth/role"
+	"github.com/bit-fever/core/auth/roles"
+	"github.com/bit-fever/core/req"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+func TestSecureValidationRules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key := newTestKey(t)
+	oc  := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+	oc.SetTokenValidation(&core.TokenValidation{
+		Issuers : []string{ testIssuer },
+		Audience: []string{ "bf-portfolio" },
+		Clients : []string{ "bf-ui", "bf-cli" },
+	})
+
+	handler := func(c *Context) {
+		_ = c.ReturnObject("ok")
+	}
+
+	router := gin.New()
+	router.GET ("/read",     oc.Secure(handler, roles.User))
+	router.POST("/activate", oc.Secure(handler, roles.User, WithValidation(&core.TokenValidation{
+		Scopes: []string{ "trading" },
+		MaxAge: 5 * time.Minute,
+	})))
+
+	claims := func(aud string, azp string, scope string, iat time.Time) map[string]any {
+		c := userClaims("bob", role.User)
+		c["aud"]   = aud
+		c["azp"]   = azp
+		c["scope"] = scope
+		c["iat"]   = iat.Unix()
+		return c
+	}
+
+	now := time.Now()
+	old := now.Add(-time.Hour)
+
+	cases := []struct {
+		method string
+		path   string
+		claims map[string]any
+		status int
+		reason string
+	}{
+		{ "GET",  "/read",     claims("bf-portfolio",  "bf-ui",  "openid",         old), http.StatusOK,           ""                      },
+		{ "GET",  "/read",     claims("bf-inventory",  "bf-ui",  "openid",         old), http.StatusUnauthorized, ReasonInvalidAudience   },
+		{ "GET",  "/read",     claims("bf-portfolio",  "other",  "openid",         old), http.StatusForbidden,    ReasonClientNotAllowed  },
+		{ "POST", "/activate", claims("bf-portfolio",  "bf-cli", "openid",         now), http.StatusForbidden,    ReasonInsufficientScope },
+		{ "POST", "/activate", claims("bf-portfolio",  "bf-cli", "openid trading", old), http.StatusUnauthorized, ReasonMaxAgeExceeded    },
+		{ "POST", "/activate", claims("bf-portfolio",  "bf-cli", "openid trading", now), http.StatusOK,           ""                      },
+	}
+
+	for i, tc := range cases {
+		rr := serve(router, tc.method, tc.path, mintToken(t, key, tc.claims), nil)
+		if rr.Code != tc.status {
+			t.Errorf("Case %d: expected status %d but got %d", i, tc.status, rr.Code)
+			continue
+		}
+
+		if tc.reason != "" {
+			var body struct { Reason string `json:"reason"` }
+			_ = json.Unmarshal(rr.Body.Bytes(), &body)
+
+			if body.Reason != tc.reason {
+				t.Errorf("Case %d: expected reason '%v' but got '%v'", i, tc.reason, body.Reason)
+			}
+		}
+	}
+}
+
+//=============================================================================
+
+func TestValidationAtServeTime(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	apiKey, hash, _ := GenerateApiKey()
+	store, err := NewConfigApiKeyStore([]core.ApiKey{
+		{ Id: "k1", Hash: hash, Owner: "scheduler", Roles: []string{ "user" }},
+	})
+	if err != nil {
+		t.Fatalf("NewConfigApiKeyStore failed: %v", err)
+	}
+
+	key := newTestKey(t)
+	oc  := NewOidcControllerWithVerifier(NewLocalKeyVerifier(testIssuer, &key.PublicKey), newTestLogger(), nil)
+
+	handler := func(c *Context) {
+		_ = c.ReturnObject("ok")
+	}
+
+	router := gin.New()
+	router.GET ("/read",  oc.Secure(handler, roles.User))
+	router.POST("/trade", oc.Secure(handler, roles.User, WithValidation(&core.TokenValidation{
+		Scopes: []string{ "trading" },
+	})))
+
+	//--- Controller settings changed after the routes were registered
+
+	oc.SetTokenValidation(&core.TokenValidation{ Audience: []string{ "bf-portfolio" } })
+	oc.SetApiKeyAuthentication(NewApiKeyAuthenticator(store), true)
+
+	claims := userClaims("bob", role.User)
+	claims["aud"] = "bf-inventory"
+
+	apiKeyHeader := map[string]string{ req.ApiKey: apiKey }
+
+	cases := []struct {
+		method  string
+		path    string
+		token   string
+		headers map[string]string
+		status  int
+		reason  string
+	}{
+		{ "GET",  "/read",  mintToken(t, key, claims), nil,          http.StatusUnauthorized, ReasonInvalidAudience   },
+		{ "GET",  "/read",  "",                        apiKeyHeader, http.StatusOK,           ""                      },
+		{ "POST", "/trade", "",                        apiKeyHeader, http.StatusForbidden,    ReasonInsufficientScope },
+	}
+
+	for i, tc := range cases {
+		rr := serve(router, tc.method, tc.path, tc.token, tc.headers)
+		if rr.Code != tc.status {
+			t.Errorf("Case %d: expected status %d but got %d", i, tc.status, rr.Code)
+			continue
+		}
+
+		var body struct { Reason string `json:"reason"` }
+		_ = json.Unmarshal(rr.Body.Bytes(), &body)
+
+		if body.Reason != tc.reason {
+			t.Errorf("Case %d: expected reason '%v' but got '%v'", i, tc.reason, body.Reason)
+		}
+	}
+
+	//--- API keys have no issue time
+
+	us := &UserSession{ AuthMethod: AuthMethodApiKey }
+	if err = ValidateSession(us, &core.TokenValidation{ MaxAge: time.Minute }); err == nil {
+		t.Errorf("Expected an API key session to fail the max age rule")
+	}
+}
+
+//=============================================================================
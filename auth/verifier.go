@@ -0,0 +1,269 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-jose/go-jose/v4"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+//=============================================================================
+
+type TokenVerifier interface {
+	Verify(ctx context.Context, rawToken string) (*VerifiedToken, error)
+}
+
+//=============================================================================
+
+type VerifiedToken struct {
+	Issuer   string
+	Subject  string
+	Audience []string
+	IssuedAt time.Time
+	Expiry   time.Time
+	Claims   json.RawMessage
+}
+
+//=============================================================================
+
+func (vt *VerifiedToken) ParseClaims(v any) error {
+	if len(vt.Claims) == 0 {
+		return errors.New("token has no claims")
+	}
+
+	return json.Unmarshal(vt.Claims, v)
+}
+
+//=============================================================================
+
+var signingAlgorithms = []string{
+	oidc.RS256, oidc.RS384, oidc.RS512,
+	oidc.ES256, oidc.ES384, oidc.ES512,
+	oidc.PS256, oidc.PS384, oidc.PS512,
+	oidc.EdDSA,
+}
+
+//=============================================================================
+//===
+//=== OIDC discovery
+//===
+//=============================================================================
+
+type oidcVerifier struct {
+	client   *http.Client
+	verifier *oidc.IDTokenVerifier
+}
+
+//=============================================================================
+
+func NewDiscoveryVerifier(authority string, client *http.Client) (TokenVerifier, error) {
+	ccontext      := oidc.ClientContext(context.Background(), client)
+	provider, err := oidc.NewProvider(ccontext, authority)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcConfig := &oidc.Config{
+		SkipClientIDCheck: true,
+	}
+
+	return &oidcVerifier{
+		client  : client,
+		verifier: provider.Verifier(oidcConfig),
+	}, nil
+}
+
+//=============================================================================
+
+func (v *oidcVerifier) Verify(ctx context.Context, rawToken string) (*VerifiedToken, error) {
+	return verifyIdToken(oidc.ClientContext(ctx, v.client), v.verifier, rawToken)
+}
+
+//=============================================================================
+//===
+//=== Static keys (JWKS file or local signing key)
+//===
+//=============================================================================
+
+type staticKeyVerifier struct {
+	verifier *oidc.IDTokenVerifier
+}
+
+//=============================================================================
+
+func NewJwksFileVerifier(issuer string, jwksFile string) (TokenVerifier, error) {
+	data, err := os.ReadFile(jwksFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var jwks jose.JSONWebKeySet
+	if err = json.Unmarshal(data, &jwks); err != nil {
+		return nil, errors.New("invalid JWKS file '"+ jwksFile +"': "+ err.Error())
+	}
+
+	var keys []crypto.PublicKey
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		if !k.IsPublic() {
+			k = k.Public()
+		}
+
+		keys = append(keys, k.Key)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys found in JWKS file: "+ jwksFile)
+	}
+
+	return NewLocalKeyVerifier(issuer, keys...), nil
+}
+
+//=============================================================================
+
+func NewLocalKeyVerifier(issuer string, keys ...crypto.PublicKey) TokenVerifier {
+	keySet := &oidc.StaticKeySet{
+		PublicKeys: keys,
+	}
+
+	oidcConfig := &oidc.Config{
+		SkipClientIDCheck   : true,
+		SupportedSigningAlgs: signingAlgorithms,
+	}
+
+	return &staticKeyVerifier{
+		verifier: oidc.NewVerifier(issuer, keySet, oidcConfig),
+	}
+}
+
+//=============================================================================
+
+func NewLocalKeyFileVerifier(issuer string, pemFile string) (TokenVerifier, error) {
+	key, err := readPublicKey(pemFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLocalKeyVerifier(issuer, key), nil
+}
+
+//=============================================================================
+
+func (v *staticKeyVerifier) Verify(ctx context.Context, rawToken string) (*VerifiedToken, error) {
+	return verifyIdToken(ctx, v.verifier, rawToken)
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func verifyIdToken(ctx context.Context, verifier *oidc.IDTokenVerifier, rawToken string) (*VerifiedToken, error) {
+	idToken, err := verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims json.RawMessage
+	if err = idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	return &VerifiedToken{
+		Issuer  : idToken.Issuer,
+		Subject : idToken.Subject,
+		Audience: idToken.Audience,
+		IssuedAt: idToken.IssuedAt,
+		Expiry  : idToken.Expiry,
+		Claims  : claims,
+	}, nil
+}
+
+//=============================================================================
+
+func readPublicKey(pemFile string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(pemFile)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found in file: "+ pemFile)
+	}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+
+	return nil, errors.New("unsupported PEM block '"+ block.Type +"' in file: "+ pemFile)
+}
+
+//=============================================================================
+//--- Decodes the payload of a JWT into claims, without verifying the signature.
+//--- The raw payload is returned as well
+
+func decodeJwtPayload(rawToken string, claims any) (json.RawMessage, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed token payload: "+ err.Error())
+	}
+
+	if err = json.Unmarshal(payload, claims); err != nil {
+		return nil, errors.New("malformed token claims: "+ err.Error())
+	}
+
+	return payload, nil
+}
+
+//=============================================================================
@@ -0,0 +1,168 @@
+//=============================================================================
+/*
+Copyright © 2025 Andrea Carboni andrea.carboni71@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+//=============================================================================
+
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/go-jose/go-jose/v4"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+const testIssuer = "https://issuer.test/realms/bf"
+
+//=============================================================================
+
+func newTestKey(t testing.TB) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Cannot generate RSA key: %v", err)
+	}
+
+	return key
+}
+
+//=============================================================================
+
+func mintToken(t testing.TB, key *rsa.PrivateKey, claims map[string]any) string {
+	opts   := (&jose.SignerOptions{}).WithType("JWT")
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, opts)
+	if err != nil {
+		t.Fatalf("Cannot create signer: %v", err)
+	}
+
+	now := time.Now()
+	all := map[string]any{
+		"iss": testIssuer,
+		"sub": "user-id",
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+
+	for k, v := range claims {
+		all[k] = v
+	}
+
+	payload, _ := json.Marshal(all)
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("Cannot sign token: %v", err)
+	}
+
+	token, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatalf("Cannot serialize token: %v", err)
+	}
+
+	return token
+}
+
+//=============================================================================
+
+func newTestLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+//=============================================================================
+
+func TestLocalKeyVerifier(t *testing.T) {
+	key   := newTestKey(t)
+	v     := NewLocalKeyVerifier(testIssuer, &key.PublicKey)
+	token := mintToken(t, key, map[string]any{ "preferred_username": "alice" })
+
+	vt, err := v.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	var ut userToken
+	if err = vt.ParseClaims(&ut); err != nil {
+		t.Fatalf("ParseClaims failed: %v", err)
+	}
+
+	if ut.Username != "alice" {
+		t.Errorf("Expected username 'alice' but got '%v'", ut.Username)
+	}
+
+	if vt.Issuer != testIssuer {
+		t.Errorf("Expected issuer '%v' but got '%v'", testIssuer, vt.Issuer)
+	}
+}
+
+//=============================================================================
+
+func TestLocalKeyVerifierRejectsBadTokens(t *testing.T) {
+	key   := newTestKey(t)
+	other := newTestKey(t)
+	v     := NewLocalKeyVerifier(testIssuer, &key.PublicKey)
+
+	tokens := map[string]string{
+		"wrong key"    : mintToken(t, other, nil),
+		"wrong issuer" : mintToken(t, key, map[string]any{ "iss": "https://other.test" }),
+		"expired"      : mintToken(t, key, map[string]any{ "exp": time.Now().Add(-time.Minute).Unix() }),
+		"garbage"      : "not-a-token",
+	}
+
+	for name, token := range tokens {
+		if _, err := v.Verify(context.Background(), token); err == nil {
+			t.Errorf("Expected an error for the '%v' token", name)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestJwksFileVerifier(t *testing.T) {
+	key  := newTestKey(t)
+	jwks := jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{{ Key: &key.PublicKey, Use: "sig", Algorithm: string(jose.RS256) }},
+	}
+
+	data, _ := json.Marshal(jwks)
+	file    := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatalf("Cannot write JWKS file: %v", err)
+	}
+
+	v, err := NewJwksFileVerifier(testIssuer, file)
+	if err != nil {
+		t.Fatalf("NewJwksFileVerifier failed: %v", err)
+	}
+
+	if _, err = v.Verify(context.Background(), mintToken(t, key, nil)); err != nil {
+		t.Errorf("Verify failed: %v", err)
+	}
+}
+
+//=============================================================================
@@ -113,22 +113,20 @@ func InitEngine(logger *slog.Logger, app *core.Application) *gin.Engine {
 func RunHttpServer(router *gin.Engine, app *core.Application) {
 
 	slog.Info("Starting HTTPS server...")
-	rootCAs, err := x509.SystemCertPool()
-	core.ExitIfError(err)
 
-	if rootCAs == nil {
-		rootCAs = x509.NewCertPool()
-	}
+	//--- Client certificates are mapped to principals, so only the internal CA is trusted
+
+	clientCAs := x509.NewCertPool()
 
 	caCert, err := os.ReadFile("config/ca.crt")
 	core.ExitIfError(err)
 
-	if ok := rootCAs.AppendCertsFromPEM(caCert); !ok {
+	if ok := clientCAs.AppendCertsFromPEM(caCert); !ok {
 		core.ExitWithMessage("Failed to append CA cert to local certificate pool")
 	}
 
 	tlsConfig := &tls.Config{
-		ClientCAs:  rootCAs,
+		ClientCAs:  clientCAs,
 		ClientAuth: tls.RequireAndVerifyClientCert,
 	}
 
@@ -27,6 +27,7 @@ package core
 import (
 	"log/slog"
 	"os"
+	"time"
 )
 
 //=============================================================================
@@ -49,9 +50,151 @@ type Database struct {
 //=============================================================================
 
 type Authentication struct {
+	Authority     string
+	ClientId      string
+	ClientSecret  string
+	AuthMethod    string
+	PrivateKey    string
+	KeyId         string
+	Scopes        []string
+	Audience      string
+	Impersonation *Impersonation
+	Profiles      []TokenProfile
+}
+
+//-----------------------------------------------------------------------------
+
+type TokenProfile struct {
+	Name          string
+	Authority     string
+	ClientId      string
+	ClientSecret  string
+	AuthMethod    string
+	PrivateKey    string
+	KeyId         string
+	Scopes        []string
+	Audience      string
+	HttpClient    string
+	Impersonation *Impersonation
+}
+
+//-----------------------------------------------------------------------------
+//--- Impersonation is disabled unless the profile lists the target audiences and
+//--- the callers (by issuer and verified subject) allowed to act for a user
+
+type Impersonation struct {
+	Audiences []string
+	Callers   []ImpersonationCaller
+}
+
+//-----------------------------------------------------------------------------
+
+type ImpersonationCaller struct {
+	Issuer  string
+	Subject string
+}
+
+//=============================================================================
+
+type DevAuthentication struct {
+	Enabled   bool
+	Username  string
+	Roles     []string
+	PublicKey string
+}
+
+//=============================================================================
+
+type TokenValidation struct {
+	Issuers  []string
+	Audience []string
+	Scopes   []string
+	Clients  []string
+	MaxAge   time.Duration
+}
+
+//=============================================================================
+
+type ClaimMapping struct {
+	Username string
+	Email    string
+	Name     string
+	Surname  string
+	Roles    []RoleMapping
+}
+
+//-----------------------------------------------------------------------------
+
+type RoleMapping struct {
+	Claim string
+	Value string
+	Role  string
+	Split bool
+}
+
+//=============================================================================
+
+type TrustedIssuer struct {
+	Name         string
 	Authority    string
-	ClientId     string
-	ClientSecret string
+	ClaimMapping ClaimMapping
+}
+
+//=============================================================================
+
+type Authorization struct {
+	Roles []RolePermissions
+}
+
+//-----------------------------------------------------------------------------
+
+type RolePermissions struct {
+	Role        string
+	Inherits    []string
+	Permissions []string
+}
+
+//=============================================================================
+
+type ApiKey struct {
+	Id         string
+	Hash       string
+	Owner      string
+	Roles      []string
+	Expiry     string
+	AllowedIps []string
+}
+
+//=============================================================================
+
+type RateLimiting struct {
+	KeyBy   string
+	Default RateLimit
+	Roles   []RoleRateLimit
+	Routes  []RouteRateLimit
+}
+
+//-----------------------------------------------------------------------------
+
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+//-----------------------------------------------------------------------------
+
+type RoleRateLimit struct {
+	Role      string
+	Exempt    bool
+	RateLimit `mapstructure:",squash"`
+}
+
+//-----------------------------------------------------------------------------
+
+type RouteRateLimit struct {
+	Route     string
+	RateLimit `mapstructure:",squash"`
 }
 
 //=============================================================================
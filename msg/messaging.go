@@ -78,6 +78,8 @@ func InitMessaging(cfg *core.Messaging) {
 	createExchange(ExEvent)
 	createQueue(QuAllToEvent)
 	bindQueue(ExEvent, QuAllToEvent)
+
+	createExchange(ExAuth)
 }
 
 //=============================================================================
@@ -135,44 +137,43 @@ func SendMessage(exchange string, source string, msgType int, entity any) error
 
 func ReceiveMessages(queue string, handler func(m *Message) bool) {
 	for {
-		messages, err := channel.Consume(queue,"",false,false,false,false,nil)
-
-		if err != nil {
-			core.ExitWithMessage("ReceiveMessages: Cannot create the consumer channel for '"+ queue +"' : "+ err.Error())
-		}
+		consume(queue, handler)
 
-		for d := range messages {
-			msg := Message{}
-			err = json.Unmarshal(d.Body, &msg)
+		slog.Warn("ReceiveMessages: Exited from for loop. Reconnecting...")
 
+		if channel.IsClosed() {
+			err := connect()
 			if err != nil {
-				slog.Error("ReceiveMessages: Error unmarshalling message. Rejecting.", "error", err.Error())
-				err = d.Reject(false)
-				if err != nil {
-					slog.Error("ReceiveMessages: Cannot reject message!", "error", err.Error())
-				}
-				continue
-			}
-
-			if handler(&msg) {
-				err = d.Ack(false)
+				core.ExitWithMessage("ReceiveMessages: Cannot reconnect to the channel: "+ err.Error())
 			} else {
-				err = d.Nack(false, true)
+				slog.Info("ReceiveMessages: Successfully reconnected")
 			}
+		}
+	}
+}
 
-			if err != nil {
-				slog.Error("ReceiveMessages: Cannot [N]acknowledge message!", "error", err.Error())
-			}
+//=============================================================================
+
+func ReceiveBroadcast(exchange string, handler func(m *Message) bool) {
+	for {
+		queue, err := createTemporaryQueue()
+		if err != nil {
+			core.ExitWithMessage("ReceiveBroadcast: Cannot create a temporary queue for '"+ exchange +"' : "+ err.Error())
 		}
 
-		slog.Warn("ReceiveMessages: Exited from for loop. Reconnecting...")
+		bindQueue(exchange, queue)
+
+		slog.Info("ReceiveBroadcast: Receiving messages", "exchange", exchange, "queue", queue)
+		consume(queue, handler)
+
+		slog.Warn("ReceiveBroadcast: Exited from for loop. Reconnecting...")
 
 		if channel.IsClosed() {
 			err = connect()
 			if err != nil {
-				core.ExitWithMessage("ReceiveMessages: Cannot reconnect to the channel: "+ err.Error())
+				core.ExitWithMessage("ReceiveBroadcast: Cannot reconnect to the channel: "+ err.Error())
 			} else {
-				slog.Info("ReceiveMessages: Successfully reconnected")
+				slog.Info("ReceiveBroadcast: Successfully reconnected")
 			}
 		}
 	}
@@ -204,6 +205,47 @@ func createQueue(name string) {
 
 //=============================================================================
 
+func consume(queue string, handler func(m *Message) bool) {
+	messages, err := channel.Consume(queue,"",false,false,false,false,nil)
+
+	if err != nil {
+		core.ExitWithMessage("Cannot create the consumer channel for '"+ queue +"' : "+ err.Error())
+	}
+
+	for d := range messages {
+		msg := Message{}
+		err = json.Unmarshal(d.Body, &msg)
+
+		if err != nil {
+			slog.Error("Error unmarshalling message. Rejecting.", "queue", queue, "error", err.Error())
+			err = d.Reject(false)
+			if err != nil {
+				slog.Error("Cannot reject message!", "queue", queue, "error", err.Error())
+			}
+			continue
+		}
+
+		if handler(&msg) {
+			err = d.Ack(false)
+		} else {
+			err = d.Nack(false, true)
+		}
+
+		if err != nil {
+			slog.Error("Cannot [N]acknowledge message!", "queue", queue, "error", err.Error())
+		}
+	}
+}
+
+//=============================================================================
+
+func createTemporaryQueue() (string, error) {
+	q, err := channel.QueueDeclare("",false,true,true,false,nil)
+	return q.Name, err
+}
+
+//=============================================================================
+
 func bindQueue(exchange, queue string) {
 	err := channel.QueueBind(queue,"",exchange,false,nil)
 
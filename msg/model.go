@@ -61,6 +61,12 @@ const (
 	//--- Queue: Event store
 
 	SourceEvent          = "event"
+	SourceAudit          = "audit"
+
+	//--- Exchange: Authentication (broadcast)
+
+	SourceRevocation     = "revocation"
+	SourceSessionChange  = "session-change"
 )
 
 //=============================================================================
@@ -84,6 +90,8 @@ const (
 
 	ExEvent                = "bf.event"
 	QuAllToEvent           = "bf.all:event"
+
+	ExAuth                 = "bf.auth"
 )
 
 //=============================================================================
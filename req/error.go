@@ -28,7 +28,10 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -38,6 +41,7 @@ import (
 type AppError struct {
 	Code    int
 	Message string
+	Reason  string
 }
 
 //-----------------------------------------------------------------------------
@@ -51,7 +55,16 @@ func (e AppError) Error() string {
 func NewBadRequestError(message string, params ...any) error {
 	return AppError {
 		Code:    http.StatusBadRequest,
-		Message: fmt.Sprintf(message, params),
+		Message: fmt.Sprintf(message, params...),
+	}
+}
+
+//=============================================================================
+
+func NewUnauthorizedError(message string, params ...any) error {
+	return AppError {
+		Code:    http.StatusUnauthorized,
+		Message: fmt.Sprintf(message, params...),
 	}
 }
 
@@ -60,7 +73,7 @@ func NewBadRequestError(message string, params ...any) error {
 func NewForbiddenError(message string, params ...any) error {
 	return AppError {
 		Code:    http.StatusForbidden,
-		Message: fmt.Sprintf(message, params),
+		Message: fmt.Sprintf(message, params...),
 	}
 }
 
@@ -69,7 +82,7 @@ func NewForbiddenError(message string, params ...any) error {
 func NewNotFoundError(message string, params ...any) error {
 	return AppError {
 		Code:    http.StatusNotFound,
-		Message: fmt.Sprintf(message, params),
+		Message: fmt.Sprintf(message, params...),
 	}
 }
 
@@ -78,7 +91,7 @@ func NewNotFoundError(message string, params ...any) error {
 func NewUnprocessableEntityError(message string, params ...any) error {
 	return AppError {
 		Code:    http.StatusUnprocessableEntity,
-		Message: fmt.Sprintf(message, params),
+		Message: fmt.Sprintf(message, params...),
 	}
 }
 
@@ -87,7 +100,7 @@ func NewUnprocessableEntityError(message string, params ...any) error {
 func NewServerError(message string, params ...any) error {
 	return AppError {
 		Code:    http.StatusInternalServerError,
-		Message: fmt.Sprintf(message, params),
+		Message: fmt.Sprintf(message, params...),
 	}
 }
 
@@ -96,7 +109,17 @@ func NewServerError(message string, params ...any) error {
 func NewServiceUnavailableError(message string, params ...any) error {
 	return AppError {
 		Code:    http.StatusServiceUnavailable,
-		Message: fmt.Sprintf(message, params),
+		Message: fmt.Sprintf(message, params...),
+	}
+}
+
+//=============================================================================
+
+func NewErrorWithReason(code int, reason string, message string, params ...any) error {
+	return AppError {
+		Code:    code,
+		Message: fmt.Sprintf(message, params...),
+		Reason:  reason,
 	}
 }
 
@@ -127,11 +150,23 @@ func ReturnForbiddenError(c *gin.Context, message string) {
 
 //=============================================================================
 
+func ReturnTooManyRequestsError(c *gin.Context, retryAfter time.Duration, message string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	writeError(c, http.StatusTooManyRequests, message)
+}
+
+//=============================================================================
+
 func ReturnError(c *gin.Context, err error) {
 	if err != nil {
 		var ae AppError
 		if errors.As(err, &ae) {
-			writeError(c, ae.Code, ae.Message)
+			writeErrorWithReason(c, ae.Code, ae.Message, ae.Reason)
 		} else {
 			writeError(c, http.StatusInternalServerError, "Found non AppError object : "+ err.Error())
 		}
@@ -147,19 +182,28 @@ func ReturnError(c *gin.Context, err error) {
 type errorResponse struct {
 	Code    int    `json:"code"`
 	Error   string `json:"error"`
+	Reason  string `json:"reason,omitempty"`
 }
 
 //-----------------------------------------------------------------------------
 
 func writeError(c *gin.Context, errorCode int, errorMessage string) {
+	writeErrorWithReason(c, errorCode, errorMessage, "")
+}
+
+//-----------------------------------------------------------------------------
+
+func writeErrorWithReason(c *gin.Context, errorCode int, errorMessage string, reason string) {
 
 	slog.Error(errorMessage,
 		"client", c.ClientIP(),
-		"code", errorCode)
+		"code", errorCode,
+		"reason", reason)
 
 	c.JSON(errorCode, &errorResponse{
 		Code:    errorCode,
 		Error:   errorMessage,
+		Reason:  reason,
 	})
 }
 
@@ -26,6 +26,7 @@ package req
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
@@ -35,6 +36,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -43,11 +45,17 @@ import (
 const (
 	ApplicationJson = "application/json"
 	OnBehalfOf      = "OnBehalfOf"
+	ApiKey          = "X-API-Key"
 )
 
 //=============================================================================
 
-var clientMap = map[string] *http.Client {}
+var clients = struct {
+	sync.RWMutex
+	clientMap map[string] *http.Client
+}{
+	clientMap: map[string] *http.Client {},
+}
 
 //=============================================================================
 //===
@@ -56,13 +64,24 @@ var clientMap = map[string] *http.Client {}
 //=============================================================================
 
 func AddClient(id string, caCert string, clientCert string, clientKey string) {
-	clientMap[id] = createClient(caCert, clientCert, clientKey)
+	SetClient(id, createClient(caCert, clientCert, clientKey))
+}
+
+//=============================================================================
+
+func SetClient(id string, client *http.Client) {
+	clients.Lock()
+	clients.clientMap[id] = client
+	clients.Unlock()
 }
 
 //=============================================================================
 
 func GetClient(id string) *http.Client {
-	return clientMap[id]
+	clients.RLock()
+	defer clients.RUnlock()
+
+	return clients.clientMap[id]
 }
 
 //=============================================================================
@@ -74,16 +93,7 @@ func DoGet(client *http.Client, url string, output any, token string) error {
 //=============================================================================
 
 func DoGetOnBehalfOf(client *http.Client, url string, output any, token string, onBehalfOf string) error {
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		slog.Error("Error creating a GET request", "error", err.Error())
-		return err
-	}
-
-	setupHeader(&req.Header, token, onBehalfOf)
-
-	res, err := client.Do(req)
-	return BuildResponse(res, err, &output)
+	return DoRequest(context.Background(), client, "GET", url, nil, output, token, onBehalfOf)
 }
 
 //=============================================================================
@@ -95,24 +105,7 @@ func DoPost(client *http.Client, url string, params any, output any, token strin
 //=============================================================================
 
 func DoPostOnBehalfOf(client *http.Client, url string, params any, output any, token string, onBehalfOf string) error {
-	body, err := json.Marshal(&params)
-	if err != nil {
-		slog.Error("Error marshalling POST parameter", "error", err.Error())
-		return err
-	}
-
-	reader := bytes.NewReader(body)
-
-	req, err := http.NewRequest("POST", url, reader)
-	if err != nil {
-		slog.Error("Error creating a POST request", "error", err.Error())
-		return err
-	}
-
-	setupHeader(&req.Header, token, onBehalfOf)
-
-	res, err := client.Do(req)
-	return BuildResponse(res, err, &output)
+	return DoRequest(context.Background(), client, "POST", url, params, output, token, onBehalfOf)
 }
 
 //=============================================================================
@@ -124,24 +117,7 @@ func DoPut(client *http.Client, url string, params any, output any, token string
 //=============================================================================
 
 func DoPutBehalfOf(client *http.Client, url string, params any, output any, token string, onBehalfOf string) error {
-	body, err := json.Marshal(&params)
-	if err != nil {
-		slog.Error("Error marshalling PUT parameter", "error", err.Error())
-		return err
-	}
-
-	reader := bytes.NewReader(body)
-
-	req, err := http.NewRequest("PUT", url, reader)
-	if err != nil {
-		slog.Error("Error creating a PUT request", "error", err.Error())
-		return err
-	}
-
-	setupHeader(&req.Header, token, onBehalfOf)
-
-	res, err := client.Do(req)
-	return BuildResponse(res, err, &output)
+	return DoRequest(context.Background(), client, "PUT", url, params, output, token, onBehalfOf)
 }
 
 //=============================================================================
@@ -153,17 +129,27 @@ func DoDelete(client *http.Client, url string, params any, output any, token str
 //=============================================================================
 
 func DoDeleteBehalfOf(client *http.Client, url string, params any, output any, token string, onBehalfOf string) error {
-	body, err := json.Marshal(&params)
-	if err != nil {
-		slog.Error("Error marshalling DELETE parameter", "error", err.Error())
-		return err
-	}
+	return DoRequest(context.Background(), client, "DELETE", url, params, output, token, onBehalfOf)
+}
+
+//=============================================================================
 
-	reader := bytes.NewReader(body)
+func DoRequest(ctx context.Context, client *http.Client, method string, url string, params any, output any, token string, onBehalfOf string) error {
+	var reader io.Reader
+
+	if method != "GET" {
+		body, err := json.Marshal(&params)
+		if err != nil {
+			slog.Error("Error marshalling "+ method +" parameter", "error", err.Error())
+			return err
+		}
+
+		reader = bytes.NewReader(body)
+	}
 
-	req, err := http.NewRequest("DELETE", url, reader)
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
 	if err != nil {
-		slog.Error("Error creating a DELETE request", "error", err.Error())
+		slog.Error("Error creating a "+ method +" request", "error", err.Error())
 		return err
 	}
 
@@ -25,11 +25,16 @@ THE SOFTWARE.
 package req
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
+	"io"
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -127,7 +132,7 @@ func GetParamAsString(c *gin.Context, name string, defValue string) string {
 func BindParamsFromQuery(c *gin.Context, obj any) (err error) {
 	if err := c.ShouldBindQuery(obj); err != nil {
 		message := parseError(err)
-		return NewBadRequestError(message, nil)
+		return NewBadRequestError("%s", message)
 	}
 
 	return nil
@@ -138,7 +143,39 @@ func BindParamsFromQuery(c *gin.Context, obj any) (err error) {
 func BindParamsFromBody(c *gin.Context, obj any) (err error) {
 	if err := c.ShouldBind(obj); err != nil {
 		message := parseError(err)
-		return NewBadRequestError(message, nil)
+		return NewBadRequestError("%s", message)
+	}
+
+	return nil
+}
+
+//=============================================================================
+//--- Binds (in order) the body, the query string and the URL parameters, then
+//--- validates the result. URL parameters use the 'uri' tag, the query the 'form'
+//--- one: only fields with an explicit tag are bound from them. The query never
+//--- overrides the body, while URL parameters always win
+
+func BindParams(c *gin.Context, obj any) error {
+	fromBody, err := bindBody(c, obj)
+	if err != nil {
+		return NewBadRequestError("%s", parseError(err))
+	}
+
+	if _, err = bindTagged(obj, c.Request.URL.Query(), "form", fromBody); err != nil {
+		return NewBadRequestError("%s", parseError(err))
+	}
+
+	params := map[string][]string{}
+	for _, p := range c.Params {
+		params[p.Key] = []string{ p.Value }
+	}
+
+	if _, err = bindTagged(obj, params, "uri", nil); err != nil {
+		return NewBadRequestError("%s", parseError(err))
+	}
+
+	if err = binding.Validator.ValidateStruct(obj); err != nil {
+		return NewBadRequestError("%s", parseError(err))
 	}
 
 	return nil
@@ -192,6 +229,14 @@ func ReturnList(c *gin.Context, result any, offset int, limit int, size int) err
 	return nil
 }
 
+//=============================================================================
+//--- Returns the name in a struct tag, without its options
+
+func TagName(f reflect.StructField, tag string) string {
+	name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+	return name
+}
+
 //=============================================================================
 //===
 //=== Private methods
@@ -215,6 +260,170 @@ func parseError(err error) string {
 	return err.Error()
 }
 
+//=============================================================================
+//--- Returns the fields set by the body
+
+func bindBody(c *gin.Context, obj any) (map[string]bool, error) {
+	if c.Request.Body == nil || c.Request.ContentLength == 0 {
+		return nil, nil
+	}
+
+	switch c.ContentType() {
+	case binding.MIMEPOSTForm, binding.MIMEMultipartPOSTForm:
+		if err := c.Request.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			return nil, err
+		}
+		return bindTagged(obj, c.Request.PostForm, "form", nil)
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, obj); err != nil {
+		return nil, err
+	}
+
+	var keys map[string]json.RawMessage
+	if err = json.Unmarshal(data, &keys); err != nil {
+		return nil, nil
+	}
+
+	return jsonFields(obj, keys), nil
+}
+
+//=============================================================================
+//--- Fields bound from the query or the URL must not be settable by the body,
+//--- unless they also have an explicit 'json' tag
+
+func jsonFields(obj any, keys map[string]json.RawMessage) map[string]bool {
+	target, ok := structValue(obj)
+	if !ok {
+		return nil
+	}
+
+	fromBody := map[string]bool{}
+
+	for _, f := range reflect.VisibleFields(target.Type()) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		name := TagName(f, "json")
+		if name == "-" {
+			continue
+		}
+
+		explicit := name != ""
+		if !explicit {
+			name = f.Name
+		}
+
+		if !hasKey(keys, name) {
+			continue
+		}
+
+		if !explicit && (TagName(f, "uri") != "" || TagName(f, "form") != "") {
+			if v, err := target.FieldByIndexErr(f.Index); err == nil {
+				v.SetZero()
+			}
+			continue
+		}
+
+		fromBody[fieldKey(f)] = true
+	}
+
+	return fromBody
+}
+
+//=============================================================================
+//--- Binds the fields with an explicit tag, skipping the given ones. Returns
+//--- the fields found in values
+
+func bindTagged(obj any, values map[string][]string, tag string, skip map[string]bool) (map[string]bool, error) {
+	target, ok := structValue(obj)
+	if !ok {
+		return nil, nil
+	}
+
+	//--- Only the explicitly tagged keys: binding falls back to field names
+
+	var fields []reflect.StructField
+	allowed := map[string][]string{}
+
+	for _, f := range reflect.VisibleFields(target.Type()) {
+		name := TagName(f, tag)
+		if !f.IsExported() || f.Anonymous || name == "" || name == "-" || skip[fieldKey(f)] {
+			continue
+		}
+
+		fields = append(fields, f)
+		if v, found := values[name]; found {
+			allowed[name] = v
+		}
+	}
+
+	//--- Binds into an empty copy, so that defaults cannot overwrite other fields
+
+	bound := reflect.New(target.Type())
+	if err := binding.MapFormWithTag(bound.Interface(), allowed, tag); err != nil {
+		return nil, err
+	}
+
+	found := map[string]bool{}
+
+	for _, f := range fields {
+		_, present := allowed[TagName(f, tag)]
+		if !present && !strings.Contains(f.Tag.Get(tag), "default=") {
+			continue
+		}
+
+		dst, err1 := target.FieldByIndexErr(f.Index)
+		src, err2 := bound.Elem().FieldByIndexErr(f.Index)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+
+		dst.Set(src)
+		if present {
+			found[fieldKey(f)] = true
+		}
+	}
+
+	return found, nil
+}
+
+//=============================================================================
+
+func structValue(obj any) (reflect.Value, bool) {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	return v.Elem(), true
+}
+
+//=============================================================================
+
+func fieldKey(f reflect.StructField) string {
+	return fmt.Sprint(f.Index)
+}
+
+//=============================================================================
+//--- Same matching rule as encoding/json
+
+func hasKey(keys map[string]json.RawMessage, name string) bool {
+	for k := range keys {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
 //=============================================================================
 
 func parseFieldError(e validator.FieldError) string {